@@ -0,0 +1,156 @@
+// Command kubectl-restart is a kubectl plugin for managing RestartSchedules
+// and ClusterRestartSchedules. Installed on the PATH, it is run as
+// "kubectl restart".
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `Manage restart schedules of the restart operator.
+
+Usage:
+  kubectl restart list [-n NAMESPACE | -A] [--cluster]
+  kubectl restart trigger NAME [-n NAMESPACE] [--cluster]
+  kubectl restart suspend NAME [-n NAMESPACE] [--cluster]
+  kubectl restart resume NAME [-n NAMESPACE] [--cluster]
+  kubectl restart history NAME [-n NAMESPACE] [--cluster]
+  kubectl restart next CRON [--tz TIME_ZONE] [--count N]
+
+Flags:
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	var (
+		kubeconfig    string
+		kubeContext   string
+		namespace     string
+		allNamespaces bool
+		cluster       bool
+		timeZone      string
+		count         int
+	)
+
+	fs := flag.NewFlagSet("kubectl-restart", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file.")
+	fs.StringVar(&kubeContext, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&namespace, "namespace", "", "Namespace of the RestartSchedule (default: namespace of the context).")
+	fs.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	fs.BoolVar(&allNamespaces, "all-namespaces", false, "List RestartSchedules in all namespaces.")
+	fs.BoolVar(&allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	fs.BoolVar(&cluster, "cluster", false, "Manage ClusterRestartSchedules instead of RestartSchedules.")
+	fs.StringVar(&timeZone, "tz", "", "IANA time zone the cron expression of next is evaluated in (default: local).")
+	fs.IntVar(&count, "count", 5, "Number of fire times printed by next.")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing command")
+	}
+	command, positional := positional[0], positional[1:]
+
+	p := &plugin{out: os.Stdout, now: time.Now, cluster: cluster}
+
+	if command == "next" {
+		if len(positional) != 1 {
+			return fmt.Errorf("next takes one cron expression, e.g. %q", "0 3 * * *")
+		}
+		return p.next(positional[0], timeZone, count)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return err
+		}
+	}
+	p.namespace = namespace
+
+	p.client, err = client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if command == "list" {
+		if len(positional) != 0 {
+			return fmt.Errorf("list takes no arguments")
+		}
+		if allNamespaces {
+			p.namespace = ""
+		}
+		return p.list(ctx)
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("%s takes the name of a schedule", command)
+	}
+	name := positional[0]
+	switch command {
+	case "trigger":
+		return p.trigger(ctx, name)
+	case "suspend":
+		return p.setSuspend(ctx, name, true)
+	case "resume":
+		return p.setSuspend(ctx, name, false)
+	case "history":
+		return p.history(ctx, name)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// parseInterspersed parses the flags in args wherever they appear, like
+// kubectl does, and returns the remaining arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
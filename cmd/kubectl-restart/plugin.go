@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/archsyscall/restart-operator/pkg/controller"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fieldManager is the field manager of the changes made by the plugin.
+const fieldManager = "kubectl-restart"
+
+// timeFormat is the format of the times printed in local time.
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+// schedule is implemented by RestartSchedule and ClusterRestartSchedule.
+type schedule interface {
+	client.Object
+	GetScheduleSpec() *v1alpha1.RestartScheduleSpec
+	GetScheduleStatus() *v1alpha1.RestartScheduleStatus
+}
+
+// plugin runs the commands of kubectl-restart against client and writes their
+// output to out.
+type plugin struct {
+	client client.Client
+	out    io.Writer
+	now    func() time.Time
+
+	// namespace of the RestartSchedules, or empty for all namespaces.
+	namespace string
+	// cluster selects ClusterRestartSchedules instead of RestartSchedules.
+	cluster bool
+}
+
+// list prints the schedules with their next and last run.
+func (p *plugin) list(ctx context.Context) error {
+	var schedules []schedule
+	if p.cluster {
+		var list v1alpha1.ClusterRestartScheduleList
+		if err := p.client.List(ctx, &list); err != nil {
+			return err
+		}
+		for i := range list.Items {
+			schedules = append(schedules, &list.Items[i])
+		}
+	} else {
+		var list v1alpha1.RestartScheduleList
+		if err := p.client.List(ctx, &list, client.InNamespace(p.namespace)); err != nil {
+			return err
+		}
+		for i := range list.Items {
+			schedules = append(schedules, &list.Items[i])
+		}
+	}
+	if len(schedules) == 0 {
+		fmt.Fprintln(p.out, "No restart schedules found.")
+		return nil
+	}
+
+	showNamespace := !p.cluster && p.namespace == ""
+	w := tabwriter.NewWriter(p.out, 0, 4, 3, ' ', 0)
+	if showNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tSCHEDULE\tSUSPENDED\tNEXT RUN\tLAST RUN\tRESULT")
+	for _, s := range schedules {
+		spec, status := s.GetScheduleSpec(), s.GetScheduleStatus()
+		if showNamespace {
+			fmt.Fprintf(w, "%s\t", s.GetNamespace())
+		}
+		lastRun, result := "<none>", "<none>"
+		if status.LastExecution != nil {
+			lastRun = formatTime(&status.LastExecution.StartTime)
+			result = string(status.LastExecution.Result)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%s\n", s.GetName(), describeSchedule(spec), spec.Suspend,
+			formatTime(status.NextScheduledTime), lastRun, result)
+	}
+	return w.Flush()
+}
+
+// trigger requests a manual run of the schedule by setting the trigger
+// annotation to the current time.
+func (p *plugin) trigger(ctx context.Context, name string) error {
+	s, err := p.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	base := s.DeepCopyObject().(schedule)
+	annotations := s.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[v1alpha1.TriggerAnnotation] = p.now().UTC().Format(time.RFC3339Nano)
+	s.SetAnnotations(annotations)
+	if err := p.client.Patch(ctx, s, client.MergeFrom(base), client.FieldOwner(fieldManager)); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "%s triggered\n", p.describe(s))
+	return nil
+}
+
+// setSuspend suspends or resumes the scheduled runs of the schedule.
+func (p *plugin) setSuspend(ctx context.Context, name string, suspend bool) error {
+	s, err := p.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	verb := "suspended"
+	if !suspend {
+		verb = "resumed"
+	}
+	if s.GetScheduleSpec().Suspend == suspend {
+		fmt.Fprintf(p.out, "%s already %s\n", p.describe(s), verb)
+		return nil
+	}
+	base := s.DeepCopyObject().(schedule)
+	s.GetScheduleSpec().Suspend = suspend
+	if err := p.client.Patch(ctx, s, client.MergeFrom(base), client.FieldOwner(fieldManager)); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "%s %s\n", p.describe(s), verb)
+	return nil
+}
+
+// history prints the most recent runs of the schedule, newest first.
+func (p *plugin) history(ctx context.Context, name string) error {
+	s, err := p.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	history := s.GetScheduleStatus().History
+	if len(history) == 0 {
+		fmt.Fprintf(p.out, "%s has not run yet.\n", p.describe(s))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tREASON\tRESULT\tDURATION\tMESSAGE")
+	for _, entry := range history {
+		duration := "<none>"
+		if entry.CompletionTime != nil {
+			duration = entry.CompletionTime.Sub(entry.StartTime.Time).Round(time.Second).String()
+		}
+		result := string(entry.Result)
+		if entry.DryRun {
+			result += " (dry run)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", formatTime(&entry.StartTime), entry.Reason, result,
+			duration, entry.Message)
+	}
+	return w.Flush()
+}
+
+// next prints the next count fire times of the cron expression. It parses the
+// expression like the operator does, so the times match the actual runs.
+func (p *plugin) next(expression, timeZone string, count int) error {
+	if count <= 0 {
+		return fmt.Errorf("--count must be positive")
+	}
+	cronSchedule, err := controller.ParseCron(expression, timeZone)
+	if err != nil {
+		return err
+	}
+	location := time.Local
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return err
+		}
+	}
+	t := p.now()
+	for range count {
+		// Next returns the zero time for expressions that never fire, such
+		// as the 30th of February.
+		if t = cronSchedule.Next(t); t.IsZero() {
+			fmt.Fprintln(p.out, "never")
+			return nil
+		}
+		fmt.Fprintln(p.out, t.In(location).Format(timeFormat))
+	}
+	return nil
+}
+
+// get reads the schedule with the given name.
+func (p *plugin) get(ctx context.Context, name string) (schedule, error) {
+	if p.cluster {
+		s := &v1alpha1.ClusterRestartSchedule{}
+		return s, p.client.Get(ctx, types.NamespacedName{Name: name}, s)
+	}
+	s := &v1alpha1.RestartSchedule{}
+	return s, p.client.Get(ctx, types.NamespacedName{Name: name, Namespace: p.namespace}, s)
+}
+
+// describe names the schedule in the output like kubectl does.
+func (p *plugin) describe(s schedule) string {
+	if p.cluster {
+		return "clusterrestartschedule.restart-operator.k8s/" + s.GetName()
+	}
+	return "restartschedule.restart-operator.k8s/" + s.GetName()
+}
+
+// describeSchedule returns the cron expression of the spec, or the policy it
+// inherits the expression from.
+func describeSchedule(spec *v1alpha1.RestartScheduleSpec) string {
+	if spec.Schedule == "" && spec.PolicyRef != nil {
+		return "<from " + spec.PolicyRef.Name + ">"
+	}
+	return spec.Schedule
+}
+
+func formatTime(t *metav1.Time) string {
+	if t == nil {
+		return "<none>"
+	}
+	return t.Local().Format(timeFormat)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var testNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestPlugin(objs ...client.Object) (*plugin, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &plugin{
+		client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		out:       out,
+		now:       func() time.Time { return testNow },
+		namespace: "default",
+	}, out
+}
+
+func TestList(t *testing.T) {
+	next := metav1.NewTime(testNow.Add(time.Hour))
+	schedules := []client.Object{
+		&v1alpha1.RestartSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec:       v1alpha1.RestartScheduleSpec{Schedule: "0 3 * * *"},
+			Status: v1alpha1.RestartScheduleStatus{
+				NextScheduledTime: &next,
+				LastExecution: &v1alpha1.RestartExecution{
+					StartTime: metav1.NewTime(testNow),
+					Result:    v1alpha1.ExecutionSucceeded,
+				},
+			},
+		},
+		&v1alpha1.RestartSchedule{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "jobs"},
+			Spec: v1alpha1.RestartScheduleSpec{
+				PolicyRef: &v1alpha1.PolicyReference{Name: "nightly"},
+				Suspend:   true,
+			},
+		},
+	}
+
+	p, out := newTestPlugin(schedules...)
+	require.NoError(t, p.list(context.Background()))
+	assert.Contains(t, out.String(), "api")
+	assert.Contains(t, out.String(), testNow.Local().Format(timeFormat))
+	assert.Contains(t, out.String(), next.Local().Format(timeFormat))
+	assert.Contains(t, out.String(), "Succeeded")
+	assert.NotContains(t, out.String(), "worker")
+
+	p.namespace = ""
+	out.Reset()
+	require.NoError(t, p.list(context.Background()))
+	assert.Contains(t, out.String(), "NAMESPACE")
+	assert.Regexp(t, `jobs\s+worker\s+<from nightly>\s+true`, out.String())
+}
+
+func TestTrigger(t *testing.T) {
+	p, out := newTestPlugin(&v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+	})
+	require.NoError(t, p.trigger(context.Background(), "api"))
+	assert.Equal(t, "restartschedule.restart-operator.k8s/api triggered\n", out.String())
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, p.client.Get(context.Background(), types.NamespacedName{Name: "api", Namespace: "default"}, &updated))
+	assert.Equal(t, testNow.Format(time.RFC3339Nano), updated.Annotations[v1alpha1.TriggerAnnotation])
+
+	assert.Error(t, p.trigger(context.Background(), "missing"))
+}
+
+func TestSuspendAndResumeClusterSchedule(t *testing.T) {
+	p, out := newTestPlugin(&v1alpha1.ClusterRestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress"},
+	})
+	p.cluster = true
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "ingress"}
+
+	require.NoError(t, p.setSuspend(ctx, "ingress", true))
+	var updated v1alpha1.ClusterRestartSchedule
+	require.NoError(t, p.client.Get(ctx, key, &updated))
+	assert.True(t, updated.Spec.Suspend)
+
+	require.NoError(t, p.setSuspend(ctx, "ingress", true))
+	require.NoError(t, p.setSuspend(ctx, "ingress", false))
+	require.NoError(t, p.client.Get(ctx, key, &updated))
+	assert.False(t, updated.Spec.Suspend)
+
+	assert.Equal(t, "clusterrestartschedule.restart-operator.k8s/ingress suspended\n"+
+		"clusterrestartschedule.restart-operator.k8s/ingress already suspended\n"+
+		"clusterrestartschedule.restart-operator.k8s/ingress resumed\n", out.String())
+}
+
+func TestHistory(t *testing.T) {
+	completed := metav1.NewTime(testNow.Add(90 * time.Second))
+	p, out := newTestPlugin(&v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Status: v1alpha1.RestartScheduleStatus{
+			History: []v1alpha1.ExecutionSummary{
+				{
+					ID:             "2",
+					Reason:         v1alpha1.ManualRestart,
+					StartTime:      metav1.NewTime(testNow),
+					CompletionTime: &completed,
+					Result:         v1alpha1.ExecutionFailed,
+					Message:        "rollout timed out",
+				},
+				{
+					ID:        "1",
+					Reason:    v1alpha1.ScheduledRestart,
+					StartTime: metav1.NewTime(testNow.Add(-24 * time.Hour)),
+					Result:    v1alpha1.ExecutionRunning,
+					DryRun:    true,
+				},
+			},
+		},
+	}, &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default"},
+	})
+
+	require.NoError(t, p.history(context.Background(), "api"))
+	assert.Regexp(t, `(?s)manual\s+Failed\s+1m30s\s+rollout timed out.*schedule\s+Running \(dry run\)\s+<none>`, out.String())
+
+	out.Reset()
+	require.NoError(t, p.history(context.Background(), "new"))
+	assert.Equal(t, "restartschedule.restart-operator.k8s/new has not run yet.\n", out.String())
+}
+
+func TestNext(t *testing.T) {
+	p, out := newTestPlugin()
+	require.NoError(t, p.next("0 3 * * *", "Asia/Tokyo", 2))
+	assert.Equal(t, "2025-03-02 03:00:00 JST\n2025-03-03 03:00:00 JST\n", out.String())
+
+	out.Reset()
+	require.NoError(t, p.next("0 0 30 2 *", "", 3))
+	assert.Equal(t, "never\n", out.String())
+
+	assert.Error(t, p.next("0 3 * * *", "Mars/Olympus_Mons", 1))
+	assert.Error(t, p.next("every night", "", 1))
+	assert.Error(t, p.next("0 3 * * *", "", 0))
+}
+
+func TestParseInterspersed(t *testing.T) {
+	var namespace string
+	var cluster bool
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&namespace, "n", "", "")
+	fs.BoolVar(&cluster, "cluster", false, "")
+
+	positional, err := parseInterspersed(fs, []string{"trigger", "api", "-n", "prod", "--cluster"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trigger", "api"}, positional)
+	assert.Equal(t, "prod", namespace)
+	assert.True(t, cluster)
+}
@@ -1,21 +1,30 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/archsyscall/restart-operator/pkg/apis/v1beta1"
 	"github.com/archsyscall/restart-operator/pkg/controller"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
@@ -26,6 +35,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	utilruntime.Must(v1beta1.AddToScheme(scheme))
 }
 
 func main() {
@@ -34,6 +44,16 @@ func main() {
 		enableLeaderElection bool
 		probeAddr            string
 		namespace            string
+		targetKindsConfigMap string
+		cloudEventsSink      string
+		otlpEndpoint         string
+		otlpInsecure         bool
+		annotationKeys       controller.AnnotationKeys
+		allowCrossNamespace  bool
+		dryRun               bool
+		enableWebhooks       bool
+		webhookPort          int
+		webhookCertDir       string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -42,6 +62,31 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&namespace, "namespace", "", "Namespace to watch (default: all namespaces)")
+	flag.StringVar(&targetKindsConfigMap, "target-kinds-configmap", "",
+		"ConfigMap (namespace/name) overriding the pod template path per target kind.")
+	flag.StringVar(&cloudEventsSink, "cloudevents-sink", "",
+		"URL that restart lifecycle events are POSTed to as CloudEvents (default: disabled).")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"OTLP HTTP endpoint URL traces are exported to. Tracing is also enabled by OTEL_EXPORTER_OTLP_ENDPOINT.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false, "Export traces over plain HTTP instead of HTTPS.")
+	flag.StringVar(&annotationKeys.RestartedAt, "restarted-at-annotation", "restart-operator.k8s/restartedAt",
+		"Pod template annotation holding the time of the restart.")
+	flag.StringVar(&annotationKeys.RestartedBy, "restarted-by-annotation", "restart-operator.k8s/restartedBy",
+		"Pod template annotation holding the namespace/name of the schedule that restarted the workload.")
+	flag.StringVar(&annotationKeys.RestartReason, "restart-reason-annotation", "restart-operator.k8s/restartReason",
+		"Pod template annotation holding why the workload was restarted.")
+	flag.StringVar(&annotationKeys.ExecutionID, "execution-id-annotation", "restart-operator.k8s/executionID",
+		"Pod template annotation holding the ID of the execution that restarted the workload.")
+	flag.BoolVar(&allowCrossNamespace, "allow-cross-namespace-targets", false,
+		"Let RestartSchedules restart workloads in other namespaces without a RestartTargetGrant.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Evaluate every run and send the restart patches as server-side dry runs instead of restarting anything.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the conversion webhook between the v1alpha1 and v1beta1 API of RestartSchedules. "+
+			"Requires a serving certificate in --webhook-cert-dir.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server listens on.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "",
+		"Directory holding tls.crt and tls.key of the webhook server (default: /tmp/k8s-webhook-server/serving-certs).")
 
 	opts := zap.Options{
 		Development: true,
@@ -59,6 +104,20 @@ func main() {
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
 		},
+		WebhookServer: webhook.NewServer(webhook.Options{
+			Port:    webhookPort,
+			CertDir: webhookCertDir,
+		}),
+		// Secrets are only read on demand for webhook headers and notifier
+		// URLs, ConfigMaps for the iCalendar data of calendars and
+		// ServiceAccounts for hook Jobs, so they are fetched directly instead
+		// of being watched cluster-wide. Only the metadata of ConfigMaps is
+		// watched, to notice calendar changes.
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}, &corev1.ServiceAccount{}},
+			},
+		},
 	}
 	if namespace != "" {
 		options.Cache = cache.Options{
@@ -68,21 +127,83 @@ func main() {
 		}
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
+	config := ctrl.GetConfigOrDie()
+	if tracingEnabled(otlpEndpoint) {
+		shutdown, err := setupTracing(context.Background(), otlpEndpoint, otlpInsecure)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			if err := shutdown(context.Background()); err != nil {
+				setupLog.Error(err, "unable to flush traces")
+			}
+		}()
+		// API requests become child spans of the reconcile or restart that
+		// issued them.
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return otelhttp.NewTransport(rt)
+		})
+	}
+
+	mgr, err := ctrl.NewManager(config, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+
+	targetKinds := controller.NewTargetKindRegistry()
+	if targetKindsConfigMap != "" {
+		cmNamespace, cmName, found := strings.Cut(targetKindsConfigMap, "/")
+		if !found {
+			setupLog.Error(nil, "target kinds ConfigMap must be given as namespace/name", "value", targetKindsConfigMap)
+			os.Exit(1)
+		}
+		key := types.NamespacedName{Namespace: cmNamespace, Name: cmName}
+		if err := controller.LoadTargetKindsFromConfigMap(context.Background(), mgr.GetAPIReader(), key, targetKinds); err != nil {
+			setupLog.Error(err, "unable to load target kinds")
+			os.Exit(1)
+		}
+	}
+
 	reconciler := controller.NewRestartScheduleReconciler(
 		mgr.GetClient(),
 		mgr.GetScheme(),
 		mgr.GetEventRecorderFor("restart-operator"),
+		targetKinds,
 	)
+	reconciler.Annotations = annotationKeys
+	reconciler.AllowCrossNamespaceTargets = allowCrossNamespace
+	reconciler.DryRun = dryRun
+	if cloudEventsSink != "" {
+		reconciler.CloudEvents = controller.NewCloudEventSink(cloudEventsSink)
+	}
 
 	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RestartSchedule")
 		os.Exit(1)
 	}
+	clusterReconciler := &controller.ClusterRestartScheduleReconciler{RestartScheduleReconciler: reconciler}
+	if err = clusterReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterRestartSchedule")
+		os.Exit(1)
+	}
+	workloadReconciler := &controller.WorkloadScheduleReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("restart-operator"),
+	}
+	if err = workloadReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WorkloadSchedule")
+		os.Exit(1)
+	}
+
+	if enableWebhooks {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(&v1beta1.RestartSchedule{}).Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RestartSchedule")
+			os.Exit(1)
+		}
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -92,6 +213,14 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		// The pod only receives conversion requests once the webhook server
+		// is listening.
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -0,0 +1,80 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=crs,categories=restart-operator
+// +kubebuilder:printcolumn:name="Target-Kind",type=string,JSONPath=`.spec.targetSelector.kind`
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Last-Restart",type=string,JSONPath=`.status.lastSuccessfulTime`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterRestartSchedule restarts workloads in any namespace. It is run by the
+// same engine as RestartSchedule and reports the same status.
+type ClusterRestartSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="[has(self.targetRef), has(self.targets), has(self.stages), has(self.targetSelector)].filter(x, x).size() == 1",message="exactly one of targetRef, targets, stages or targetSelector must be set"
+	// +kubebuilder:validation:XValidation:rule="!has(self.canary) || has(self.targets) || has(self.targetSelector)",message="canary requires targets or targetSelector"
+	// +kubebuilder:validation:XValidation:rule="!has(self.targetRef) || has(self.targetRef.__namespace__)",message="targetRef.namespace is required"
+	// +kubebuilder:validation:XValidation:rule="!has(self.targets) || self.targets.all(t, has(t.__namespace__))",message="targets require a namespace"
+	// +kubebuilder:validation:XValidation:rule="!has(self.stages) || self.stages.all(s, s.targets.all(t, has(t.__namespace__)))",message="stage targets require a namespace"
+	// +kubebuilder:validation:XValidation:rule="!has(self.hooks)",message="hooks are not supported by cluster schedules"
+	// +kubebuilder:validation:XValidation:rule="!has(self.webhooks) || self.webhooks.all(w, !has(w.headerFrom))",message="webhook headerFrom is not supported by cluster schedules"
+	// +kubebuilder:validation:XValidation:rule="!has(self.deleteWithTarget) || !self.deleteWithTarget",message="deleteWithTarget is not supported by cluster schedules"
+	// +kubebuilder:validation:XValidation:rule="has(self.schedule) || has(self.policyRef)",message="schedule is required unless policyRef is set"
+	// +kubebuilder:validation:XValidation:rule="!has(self.policyRef) || self.policyRef.kind == 'ClusterRestartPolicy'",message="cluster schedules can only reference a ClusterRestartPolicy"
+	Spec   ClusterRestartScheduleSpec `json:"spec,omitempty"`
+	Status RestartScheduleStatus      `json:"status,omitempty"`
+}
+
+type ClusterRestartScheduleSpec struct {
+	// Targets of a cluster schedule must name their namespace. Hooks,
+	// webhook headers and deleteWithTarget are not supported, since they
+	// refer to objects in the namespace of the schedule.
+	RestartScheduleSpec `json:",inline"`
+
+	// TargetSelector restarts every workload of a kind that matches the
+	// selectors, resolved anew for every run.
+	// +optional
+	TargetSelector *ClusterTargetSelector `json:"targetSelector,omitempty"`
+}
+
+type ClusterTargetSelector struct {
+	// APIVersion of the workloads, defaults to apps/v1 for Deployment,
+	// StatefulSet and DaemonSet.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+
+	// NamespaceSelector limits the namespaces searched for workloads. All
+	// namespaces are searched when it is omitted.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selector matches the labels of the workloads. All workloads of the kind
+	// match when it is omitted.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type ClusterRestartScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRestartSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterRestartSchedule{}, &ClusterRestartScheduleList{})
+}
@@ -0,0 +1,65 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=rcal,categories=restart-operator
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RestartCalendar lists the days on which the scheduled runs of the schedules
+// referencing it are skipped, e.g. public holidays. Calendars are
+// cluster-scoped, so one calendar can serve every namespace.
+type RestartCalendar struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RestartCalendarSpec `json:"spec,omitempty"`
+}
+
+type RestartCalendarSpec struct {
+	// +optional
+	Dates []CalendarDate `json:"dates,omitempty"`
+
+	// ConfigMapRef refers to iCalendar data, e.g. an exported .ics file. Every
+	// VEVENT in it is a day, or a range of days, to skip. Yearly recurring
+	// events are supported.
+	// +optional
+	ConfigMapRef *ConfigMapKeyReference `json:"configMapRef,omitempty"`
+}
+
+// CalendarDate is a day to skip.
+type CalendarDate struct {
+	// Date in the format YYYY-MM-DD, in the time zone of the schedule.
+	// +kubebuilder:validation:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	Date string `json:"date"`
+
+	// Name is reported when a run is skipped on the date.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+type ConfigMapKeyReference struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Key holding the iCalendar data. Defaults to calendar.ics.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type RestartCalendarList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartCalendar `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartCalendar{}, &RestartCalendarList{})
+}
@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=rn,categories=restart-operator
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RestartNotifier sends a message to a chat incoming webhook whenever a
+// scheduled restart of a matching RestartSchedule in its namespace starts or
+// finishes.
+type RestartNotifier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RestartNotifierSpec `json:"spec"`
+}
+
+type NotifierType string
+
+const (
+	SlackNotifier NotifierType = "Slack"
+	TeamsNotifier NotifierType = "Teams"
+)
+
+type NotificationEvent string
+
+const (
+	NotificationStarted   NotificationEvent = "Started"
+	NotificationSucceeded NotificationEvent = "Succeeded"
+	NotificationFailed    NotificationEvent = "Failed"
+	NotificationSkipped   NotificationEvent = "Skipped"
+)
+
+type RestartNotifierSpec struct {
+	// Selector picks the RestartSchedules in the namespace of the notifier
+	// whose restarts are reported. When omitted, all of them are.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Events limits the notifications to these events. When empty, every
+	// event is sent.
+	// +optional
+	Events []NotificationEvent `json:"events,omitempty"`
+
+	// Type selects the message format of the incoming webhook.
+	// +kubebuilder:validation:Enum=Slack;Teams
+	// +kubebuilder:default=Slack
+	// +optional
+	Type NotifierType `json:"type,omitempty"`
+
+	// URLFrom refers to the key of a Secret in the namespace of the notifier
+	// that holds the incoming webhook URL. The Secret must carry the
+	// WebhookCredentialLabel set to "true".
+	// +kubebuilder:validation:Required
+	URLFrom corev1.SecretKeySelector `json:"urlFrom"`
+
+	// Template is a Go template rendering the message text. It is executed
+	// with .Event, .Schedule and .Execution.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type RestartNotifierList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartNotifier `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartNotifier{}, &RestartNotifierList{})
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=rpol,categories=restart-operator
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Time-Zone",type=string,JSONPath=`.spec.timeZone`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RestartPolicy bundles settings shared by the RestartSchedules in its
+// namespace that reference it.
+type RestartPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RestartPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=crpol,categories=restart-operator
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Time-Zone",type=string,JSONPath=`.spec.timeZone`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ClusterRestartPolicy bundles settings shared by RestartSchedules in any
+// namespace and by ClusterRestartSchedules.
+type ClusterRestartPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RestartPolicySpec `json:"spec,omitempty"`
+}
+
+// RestartPolicySpec holds the defaults a policy provides. A field set on the
+// schedule takes precedence over the same field of the policy, except for
+// blackout windows, calendars and webhooks, which are merged by name, and
+// excluded dates, which are added up.
+type RestartPolicySpec struct {
+	// +kubebuilder:validation:Pattern=`^(\d+|\*)(/\d+)?(\s+(\d+|\*)(/\d+)?){4}$`
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// +optional
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+
+	// +kubebuilder:validation:items:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	// +optional
+	ExcludeDates []string `json:"excludeDates,omitempty"`
+
+	// +optional
+	CalendarRefs []CalendarReference `json:"calendarRefs,omitempty"`
+
+	// Canary applies to schedules restarting a list of targets.
+	// +optional
+	Canary *CanaryPolicy `json:"canary,omitempty"`
+
+	// +optional
+	RolloutTimeout *metav1.Duration `json:"rolloutTimeout,omitempty"`
+
+	// +optional
+	HealthGate *HealthGate `json:"healthGate,omitempty"`
+
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// +optional
+	Webhooks []RestartWebhook `json:"webhooks,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type RestartPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartPolicy `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+
+type ClusterRestartPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRestartPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartPolicy{}, &RestartPolicyList{}, &ClusterRestartPolicy{}, &ClusterRestartPolicyList{})
+}
@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=rtg,categories=restart-operator
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RestartTargetGrant allows RestartSchedules in other namespaces to restart
+// workloads in the namespace of the grant. Without a grant, a RestartSchedule
+// may only restart workloads in its own namespace.
+type RestartTargetGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RestartTargetGrantSpec `json:"spec"`
+}
+
+type RestartTargetGrantSpec struct {
+	// From lists the namespaces whose RestartSchedules are granted access.
+	// +kubebuilder:validation:MinItems=1
+	From []RestartTargetGrantFrom `json:"from"`
+
+	// To limits the workloads that may be restarted. When empty, every
+	// workload in the namespace may be.
+	// +optional
+	To []RestartTargetGrantTo `json:"to,omitempty"`
+}
+
+type RestartTargetGrantFrom struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+type RestartTargetGrantTo struct {
+	// Group of the kind. When omitted, it is the group the kind resolves to in
+	// a target without apiVersion, e.g. apps for Deployment.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+
+	// Name of the workload. When omitted, every workload of the kind may be
+	// restarted.
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type RestartTargetGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartTargetGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartTargetGrant{}, &RestartTargetGrantList{})
+}
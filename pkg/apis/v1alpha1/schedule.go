@@ -0,0 +1,21 @@
+package v1alpha1
+
+// GetScheduleSpec returns the spec run by the restart engine.
+func (s *RestartSchedule) GetScheduleSpec() *RestartScheduleSpec {
+	return &s.Spec
+}
+
+// GetScheduleStatus returns the status written by the restart engine.
+func (s *RestartSchedule) GetScheduleStatus() *RestartScheduleStatus {
+	return &s.Status
+}
+
+// GetScheduleSpec returns the spec run by the restart engine.
+func (s *ClusterRestartSchedule) GetScheduleSpec() *RestartScheduleSpec {
+	return &s.Spec.RestartScheduleSpec
+}
+
+// GetScheduleStatus returns the status written by the restart engine.
+func (s *ClusterRestartSchedule) GetScheduleStatus() *RestartScheduleStatus {
+	return &s.Status
+}
@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -10,6 +12,8 @@ import (
 // +kubebuilder:printcolumn:name="Target-Kind",type=string,JSONPath=`.spec.targetRef.kind`
 // +kubebuilder:printcolumn:name="Target-Name",type=string,JSONPath=`.spec.targetRef.name`
 // +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 // +kubebuilder:printcolumn:name="Last-Restart",type=string,JSONPath=`.status.lastSuccessfulTime`
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
@@ -17,22 +21,334 @@ type RestartSchedule struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
+	// +kubebuilder:validation:XValidation:rule="[has(self.targetRef), has(self.targets), has(self.stages)].filter(x, x).size() == 1",message="exactly one of targetRef, targets or stages must be set"
+	// +kubebuilder:validation:XValidation:rule="!has(self.canary) || has(self.targets)",message="canary requires targets"
+	// +kubebuilder:validation:XValidation:rule="has(self.schedule) || has(self.policyRef)",message="schedule is required unless policyRef is set"
 	Spec   RestartScheduleSpec   `json:"spec,omitempty"`
 	Status RestartScheduleStatus `json:"status,omitempty"`
 }
 
+// RestartScheduleSpec is shared by RestartSchedule and ClusterRestartSchedule.
 type RestartScheduleSpec struct {
-	// +kubebuilder:validation:Required
+	// Schedule is the cron expression of the restarts. It may be omitted
+	// when the referenced policy provides one.
 	// +kubebuilder:validation:Pattern=`^(\d+|\*)(/\d+)?(\s+(\d+|\*)(/\d+)?){4}$`
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// PolicyRef fills in the fields left unset here from a RestartPolicy in
+	// the namespace of the schedule or from a ClusterRestartPolicy.
+	// +optional
+	PolicyRef *PolicyReference `json:"policyRef,omitempty"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in, e.g.
+	// Europe/Berlin. The time zone of the operator is used when it is empty.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Jitter delays every run by a random duration of up to the given
+	// length, so schedules sharing a cron expression do not all restart at
+	// the same moment.
+	// +optional
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+
+	// BlackoutWindows are periods in which scheduled restarts are skipped.
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+
+	// ExcludeDates are days, in the format YYYY-MM-DD and in the time zone
+	// of the schedule, on which scheduled runs are skipped.
+	// +kubebuilder:validation:items:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	// +optional
+	ExcludeDates []string `json:"excludeDates,omitempty"`
+
+	// CalendarRefs name RestartCalendars listing further days to skip.
+	// +optional
+	CalendarRefs []CalendarReference `json:"calendarRefs,omitempty"`
+
+	// Suspend stops scheduled runs. Runs requested with the trigger
+	// annotation still start.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun evaluates the gates and targets of every run and sends the
+	// restart patches as server-side dry runs, so nothing is restarted.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// PreviewCount is the number of upcoming runs listed in
+	// status.upcomingRuns. Defaults to 3; 0 turns the preview off.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=20
+	// +optional
+	PreviewCount *int32 `json:"previewCount,omitempty"`
+
+	// TargetRef is the single workload to restart.
+	// +optional
+	TargetRef *TargetRef `json:"targetRef,omitempty"`
+
+	// Targets restarts several workloads together, or canary first when
+	// Canary is set.
+	// +kubebuilder:validation:MinItems=1
+	// +optional
+	Targets []TargetRef `json:"targets,omitempty"`
+
+	// Canary restarts part of Targets first and only restarts the rest once
+	// those have rolled out and stayed healthy for the soak period.
+	// +optional
+	Canary *CanaryPolicy `json:"canary,omitempty"`
+
+	// Stages restarts several workloads in order. Each stage waits until the
+	// rollout of all its targets has completed before the next one starts, and
+	// a failing stage aborts the rest of the sequence.
+	// +kubebuilder:validation:MinItems=1
+	// +optional
+	Stages []RestartStage `json:"stages,omitempty"`
+
+	// RolloutTimeout bounds how long a stage waits for its targets to finish
+	// rolling out. Defaults to 10m.
+	// +optional
+	RolloutTimeout *metav1.Duration `json:"rolloutTimeout,omitempty"`
+
+	// Hooks are Jobs run before and after every scheduled restart.
+	// +optional
+	Hooks *RestartHooks `json:"hooks,omitempty"`
+
+	// Webhooks are called over HTTP before and after every scheduled restart.
+	// +optional
+	Webhooks []RestartWebhook `json:"webhooks,omitempty"`
+
+	// HealthGate must report the workloads as healthy before a restart starts
+	// and keep doing so for a soak period after the rollout has completed.
+	// +optional
+	HealthGate *HealthGate `json:"healthGate,omitempty"`
+
+	// RetryPolicy controls how a failed restart is retried before the run is
+	// given up until the next scheduled time.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// CleanupOnDelete removes the annotations the schedule wrote to the pod
+	// templates of its targets when the schedule is deleted. Removing them
+	// rolls the targets out once more.
+	// +optional
+	CleanupOnDelete bool `json:"cleanupOnDelete,omitempty"`
+
+	// DeleteWithTarget makes the targets in the namespace of the schedule its
+	// owners, so the schedule is garbage-collected once all of them have
+	// been deleted.
+	// +optional
+	DeleteWithTarget bool `json:"deleteWithTarget,omitempty"`
+}
+
+type HealthGate struct {
+	// Prometheus evaluates a PromQL query against a threshold.
+	// +optional
+	Prometheus *PrometheusHealthGate `json:"prometheus,omitempty"`
+}
+
+type ComparisonOperator string
+
+const (
+	LessThan           ComparisonOperator = "LessThan"
+	LessThanOrEqual    ComparisonOperator = "LessThanOrEqual"
+	GreaterThan        ComparisonOperator = "GreaterThan"
+	GreaterThanOrEqual ComparisonOperator = "GreaterThanOrEqual"
+)
+
+type PrometheusHealthGate struct {
+	// Address is the base URL of the Prometheus server, e.g.
+	// http://prometheus.monitoring:9090.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Address string `json:"address"`
+
+	// Query is an instant PromQL query. The gate is open when it returns at
+	// least one sample and every sample compares true against Threshold.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Query string `json:"query"`
+
+	// Threshold is the decimal number the samples are compared against.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^-?[0-9]+(\.[0-9]+)?$`
+	Threshold string `json:"threshold"`
+
+	// +kubebuilder:validation:Enum=LessThan;LessThanOrEqual;GreaterThan;GreaterThanOrEqual
+	// +kubebuilder:default=LessThan
+	// +optional
+	Operator ComparisonOperator `json:"operator,omitempty"`
+
+	// SoakDuration is how long the query has to keep passing after the
+	// rollout of all targets has completed.
+	// +kubebuilder:default="5m"
+	// +optional
+	SoakDuration *metav1.Duration `json:"soakDuration,omitempty"`
+}
+
+// RestartHooks are run as Jobs in the namespace of the schedule. Their pods
+// run as the default ServiceAccount unless the template names one carrying
+// the HookServiceAccountLabel set to "true".
+type RestartHooks struct {
+	// PreRestart is run to completion before the first target is restarted.
+	// If it fails, the run is aborted without restarting anything.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PreRestart *batchv1.JobTemplateSpec `json:"preRestart,omitempty"`
+
+	// PostRestart is run to completion after all targets have been restarted.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PostRestart *batchv1.JobTemplateSpec `json:"postRestart,omitempty"`
+
+	// Timeout bounds how long the operator waits for a hook Job to finish.
+	// A Job that is still running by then is deleted.
+	// +kubebuilder:default="10m"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type WebhookType string
+
+const (
+	// GateWebhook is called before the restart, and a non-2xx response or a
+	// failed request vetoes it.
+	GateWebhook WebhookType = "Gate"
+	// NotifyWebhook is called before and after the restart and never affects it.
+	NotifyWebhook WebhookType = "Notify"
+)
+
+type RestartWebhook struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// +kubebuilder:validation:Enum=Gate;Notify
+	// +kubebuilder:default=Notify
+	// +optional
+	Type WebhookType `json:"type,omitempty"`
+
+	// +kubebuilder:default="10s"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// CABundle is a PEM encoded CA bundle used to verify the server certificate.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// HeaderFrom adds a header whose value is read from a Secret in the
+	// namespace of the schedule, e.g. an Authorization token. The Secret
+	// must carry the WebhookCredentialLabel set to "true".
+	// +optional
+	HeaderFrom *WebhookHeaderSource `json:"headerFrom,omitempty"`
+}
+
+type WebhookHeaderSource struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Required
+	SecretKeyRef corev1.SecretKeySelector `json:"secretKeyRef"`
+}
+
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per run, including the first.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +kubebuilder:default=3
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+
+	// Backoff is the delay before the first retry. It doubles after every
+	// further failed attempt, up to 10m.
+	// +kubebuilder:default="30s"
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+}
+
+type PolicyReference struct {
+	// Kind is RestartPolicy or ClusterRestartPolicy.
+	// +kubebuilder:validation:Enum=RestartPolicy;ClusterRestartPolicy
+	// +kubebuilder:default=RestartPolicy
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+type CalendarReference struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+type BlackoutWindow struct {
+	// Name identifies the window in the message of skipped runs.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Schedule is the cron expression of the start of the window, evaluated
+	// in the time zone of the restart schedule.
+	// +kubebuilder:validation:Required
 	Schedule string `json:"schedule"`
 
+	// Duration is how long the window lasts from every start.
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// +kubebuilder:validation:XValidation:rule="!(has(self.count) && has(self.percentage))",message="only one of count or percentage may be set"
+type CanaryPolicy struct {
+	// Count is the number of targets restarted first. Defaults to 1 when
+	// Percentage is not set either.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+
+	// Percentage of the targets restarted first, rounded up to at least one.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percentage *int32 `json:"percentage,omitempty"`
+
+	// SoakDuration is how long the canary targets must stay healthy after their
+	// rollout completes before the remaining targets are restarted.
+	// +kubebuilder:default="5m"
+	// +optional
+	SoakDuration *metav1.Duration `json:"soakDuration,omitempty"`
+}
+
+type RestartStage struct {
 	// +kubebuilder:validation:Required
-	TargetRef TargetRef `json:"targetRef"`
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Targets are restarted together when the stage starts.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Targets []TargetRef `json:"targets"`
 }
 
 type TargetRef struct {
+	// APIVersion of the target. Defaults to apps/v1 for Deployment, StatefulSet
+	// and DaemonSet, and is required for any other kind.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	// +kubebuilder:validation:MinLength=1
 	Kind string `json:"kind"`
 
 	// +kubebuilder:validation:Required
@@ -44,14 +360,213 @@ type TargetRef struct {
 }
 
 type RestartScheduleStatus struct {
+	// ObservedGeneration is the generation of the spec the status was last
+	// reconciled from.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// +optional
 	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
 
+	// NextScheduledTime is the first of the upcoming runs.
 	// +optional
 	NextScheduledTime *metav1.Time `json:"nextScheduledTime,omitempty"`
 
+	// UpcomingRuns previews the next runs, leaving out those that may fall
+	// into a blackout window.
+	// +optional
+	UpcomingRuns []UpcomingRun `json:"upcomingRuns,omitempty"`
+
+	// Conditions are Valid, TargetFound, TargetReady, RestartFailed and
+	// Ready, which summarizes the others.
+	// +listType=map
+	// +listMapKey=type
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastExecution records the most recent scheduled run and its attempts.
+	// +optional
+	LastExecution *RestartExecution `json:"lastExecution,omitempty"`
+
+	// Target summarizes the current state of the targets. It is refreshed
+	// whenever a Deployment, StatefulSet or DaemonSet target changes.
+	// +optional
+	Target *TargetStatus `json:"target,omitempty"`
+
+	// History summarizes the most recent runs, newest first.
+	// +optional
+	History []ExecutionSummary `json:"history,omitempty"`
+
+	// LastHandledTrigger is the value of the trigger annotation for which the
+	// last manual run was started.
+	// +optional
+	LastHandledTrigger string `json:"lastHandledTrigger,omitempty"`
+}
+
+// UpcomingRun is a run expected to start between Time and LatestTime, which
+// is only set when the schedule has a jitter.
+type UpcomingRun struct {
+	Time metav1.Time `json:"time"`
+
+	// +optional
+	LatestTime *metav1.Time `json:"latestTime,omitempty"`
+}
+
+// ExecutionSummary is the entry of a finished run in the history.
+type ExecutionSummary struct {
+	ID string `json:"id"`
+
+	// +optional
+	Reason RestartReason `json:"reason,omitempty"`
+
+	StartTime metav1.Time `json:"startTime"`
+
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	Result ExecutionResult `json:"result"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// TargetStatus adds up the replicas of all targets of a schedule.
+type TargetStatus struct {
+	// Replicas is the number of pods the targets should run.
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of pods of the targets that are ready.
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Revision is the revision the target currently runs. It is only set
+	// for schedules with a single target.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+}
+
+type ExecutionResult string
+
+const (
+	ExecutionRunning   ExecutionResult = "Running"
+	ExecutionSucceeded ExecutionResult = "Succeeded"
+	ExecutionFailed    ExecutionResult = "Failed"
+	ExecutionSkipped   ExecutionResult = "Skipped"
+)
+
+// TriggerAnnotation requests a manual run of a schedule whenever it is set
+// to a new value, e.g. the current time.
+const TriggerAnnotation = "restart-operator.k8s/trigger"
+
+// WebhookCredentialLabel marks Secrets that webhook headers and notifier
+// URLs may be read from. Without it, anyone allowed to create a schedule or
+// notifier could have any Secret of its namespace sent to a URL of their
+// choosing.
+const WebhookCredentialLabel = "restart-operator.k8s/webhook-credential"
+
+// HookServiceAccountLabel marks ServiceAccounts that hook Jobs may run as.
+// Without it, anyone allowed to create a schedule could run pods with the
+// permissions of any ServiceAccount of its namespace.
+const HookServiceAccountLabel = "restart-operator.k8s/hook-service-account"
+
+// RestartReason tells why an execution was started.
+type RestartReason string
+
+const (
+	// ScheduledRestart executions are started by the cron schedule.
+	ScheduledRestart RestartReason = "schedule"
+	// ManualRestart executions are requested by a user.
+	ManualRestart RestartReason = "manual"
+)
+
+type RestartExecution struct {
+	// ID identifies the execution. It is also written to the pod template of
+	// every target restarted by it.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// +kubebuilder:validation:Enum=schedule;manual
+	// +optional
+	Reason RestartReason `json:"reason,omitempty"`
+
+	StartTime metav1.Time `json:"startTime"`
+
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	Result ExecutionResult `json:"result"`
+
+	// Message explains why the execution failed or was skipped.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// DryRun is set for executions that did not restart anything.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// +optional
+	Attempts []RestartAttempt `json:"attempts,omitempty"`
+
+	// Stages reports the progress of each stage that has been started.
+	// +optional
+	Stages []StageStatus `json:"stages,omitempty"`
+
+	// Hooks reports the hook Jobs run as part of the execution.
+	// +optional
+	Hooks []HookStatus `json:"hooks,omitempty"`
+}
+
+type HookPhase string
+
+const (
+	PreRestartHook  HookPhase = "PreRestart"
+	PostRestartHook HookPhase = "PostRestart"
+)
+
+type HookStatus struct {
+	Phase HookPhase `json:"phase"`
+
+	JobName string `json:"jobName"`
+
+	Result ExecutionResult `json:"result"`
+
+	StartTime metav1.Time `json:"startTime"`
+
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type StageStatus struct {
+	Name string `json:"name"`
+
+	Result ExecutionResult `json:"result"`
+
+	StartTime metav1.Time `json:"startTime"`
+
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type RestartAttempt struct {
+	// Stage is the name of the stage the attempt belongs to.
+	// +optional
+	Stage string `json:"stage,omitempty"`
+
+	Attempt int32 `json:"attempt"`
+
+	Time metav1.Time `json:"time"`
+
+	// Error is the failure message, empty for a successful attempt.
+	// +optional
+	Error string `json:"error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
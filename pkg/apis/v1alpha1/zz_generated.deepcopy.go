@@ -3,15 +3,672 @@
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+func (in *BlackoutWindow) DeepCopyInto(out *BlackoutWindow) {
+	*out = *in
+}
+
+func (in *BlackoutWindow) DeepCopy() *BlackoutWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BlackoutWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *CalendarDate) DeepCopyInto(out *CalendarDate) {
+	*out = *in
+}
+
+func (in *CalendarDate) DeepCopy() *CalendarDate {
+	if in == nil {
+		return nil
+	}
+	out := new(CalendarDate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *CalendarReference) DeepCopyInto(out *CalendarReference) {
+	*out = *in
+}
+
+func (in *CalendarReference) DeepCopy() *CalendarReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CalendarReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *CanaryPolicy) DeepCopyInto(out *CanaryPolicy) {
+	*out = *in
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Percentage != nil {
+		in, out := &in.Percentage, &out.Percentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SoakDuration != nil {
+		in, out := &in.SoakDuration, &out.SoakDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+func (in *CanaryPolicy) DeepCopy() *CanaryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterRestartPolicy) DeepCopyInto(out *ClusterRestartPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *ClusterRestartPolicy) DeepCopy() *ClusterRestartPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRestartPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterRestartPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *ClusterRestartPolicyList) DeepCopyInto(out *ClusterRestartPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterRestartPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *ClusterRestartPolicyList) DeepCopy() *ClusterRestartPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRestartPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterRestartPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *ClusterRestartSchedule) DeepCopyInto(out *ClusterRestartSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *ClusterRestartSchedule) DeepCopy() *ClusterRestartSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRestartSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterRestartSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *ClusterRestartScheduleList) DeepCopyInto(out *ClusterRestartScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterRestartSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *ClusterRestartScheduleList) DeepCopy() *ClusterRestartScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRestartScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterRestartScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *ClusterRestartScheduleSpec) DeepCopyInto(out *ClusterRestartScheduleSpec) {
+	*out = *in
+	in.RestartScheduleSpec.DeepCopyInto(&out.RestartScheduleSpec)
+	if in.TargetSelector != nil {
+		in, out := &in.TargetSelector, &out.TargetSelector
+		*out = new(ClusterTargetSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *ClusterRestartScheduleSpec) DeepCopy() *ClusterRestartScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterRestartScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ClusterTargetSelector) DeepCopyInto(out *ClusterTargetSelector) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *ClusterTargetSelector) DeepCopy() *ClusterTargetSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterTargetSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ExecutionSummary) DeepCopyInto(out *ExecutionSummary) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+func (in *ExecutionSummary) DeepCopy() *ExecutionSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecutionSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *HealthGate) DeepCopyInto(out *HealthGate) {
+	*out = *in
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusHealthGate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *HealthGate) DeepCopy() *HealthGate {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *HookStatus) DeepCopyInto(out *HookStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+func (in *HookStatus) DeepCopy() *HookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PolicyReference) DeepCopyInto(out *PolicyReference) {
+	*out = *in
+}
+
+func (in *PolicyReference) DeepCopy() *PolicyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *PrometheusHealthGate) DeepCopyInto(out *PrometheusHealthGate) {
+	*out = *in
+	if in.SoakDuration != nil {
+		in, out := &in.SoakDuration, &out.SoakDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+func (in *PrometheusHealthGate) DeepCopy() *PrometheusHealthGate {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusHealthGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartAttempt) DeepCopyInto(out *RestartAttempt) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+func (in *RestartAttempt) DeepCopy() *RestartAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartCalendar) DeepCopyInto(out *RestartCalendar) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *RestartCalendar) DeepCopy() *RestartCalendar {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartCalendar)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartCalendar) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartCalendarList) DeepCopyInto(out *RestartCalendarList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartCalendar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartCalendarList) DeepCopy() *RestartCalendarList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartCalendarList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartCalendarList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartCalendarSpec) DeepCopyInto(out *RestartCalendarSpec) {
+	*out = *in
+	if in.Dates != nil {
+		in, out := &in.Dates, &out.Dates
+		*out = make([]CalendarDate, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+}
+
+func (in *RestartCalendarSpec) DeepCopy() *RestartCalendarSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartCalendarSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartExecution) DeepCopyInto(out *RestartExecution) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Attempts != nil {
+		in, out := &in.Attempts, &out.Attempts
+		*out = make([]RestartAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]StageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = make([]HookStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartExecution) DeepCopy() *RestartExecution {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartExecution)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartHooks) DeepCopyInto(out *RestartHooks) {
+	*out = *in
+	if in.PreRestart != nil {
+		in, out := &in.PreRestart, &out.PreRestart
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostRestart != nil {
+		in, out := &in.PostRestart, &out.PostRestart
+		*out = new(batchv1.JobTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+func (in *RestartHooks) DeepCopy() *RestartHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartNotifier) DeepCopyInto(out *RestartNotifier) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *RestartNotifier) DeepCopy() *RestartNotifier {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartNotifier)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartNotifier) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartNotifierList) DeepCopyInto(out *RestartNotifierList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartNotifier, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartNotifierList) DeepCopy() *RestartNotifierList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartNotifierList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartNotifierList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartNotifierSpec) DeepCopyInto(out *RestartNotifierSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]NotificationEvent, len(*in))
+		copy(*out, *in)
+	}
+	in.URLFrom.DeepCopyInto(&out.URLFrom)
+}
+
+func (in *RestartNotifierSpec) DeepCopy() *RestartNotifierSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartNotifierSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartPolicy) DeepCopyInto(out *RestartPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *RestartPolicy) DeepCopy() *RestartPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartPolicyList) DeepCopyInto(out *RestartPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartPolicyList) DeepCopy() *RestartPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartPolicySpec) DeepCopyInto(out *RestartPolicySpec) {
+	*out = *in
+	if in.Jitter != nil {
+		in, out := &in.Jitter, &out.Jitter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BlackoutWindows != nil {
+		in, out := &in.BlackoutWindows, &out.BlackoutWindows
+		*out = make([]BlackoutWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeDates != nil {
+		in, out := &in.ExcludeDates, &out.ExcludeDates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CalendarRefs != nil {
+		in, out := &in.CalendarRefs, &out.CalendarRefs
+		*out = make([]CalendarReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RolloutTimeout != nil {
+		in, out := &in.RolloutTimeout, &out.RolloutTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.HealthGate != nil {
+		in, out := &in.HealthGate, &out.HealthGate
+		*out = new(HealthGate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]RestartWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartPolicySpec) DeepCopy() *RestartPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 func (in *RestartSchedule) DeepCopyInto(out *RestartSchedule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -62,7 +719,85 @@ func (in *RestartScheduleList) DeepCopyObject() runtime.Object {
 
 func (in *RestartScheduleSpec) DeepCopyInto(out *RestartScheduleSpec) {
 	*out = *in
-	out.TargetRef = in.TargetRef
+	if in.PolicyRef != nil {
+		in, out := &in.PolicyRef, &out.PolicyRef
+		*out = new(PolicyReference)
+		**out = **in
+	}
+	if in.Jitter != nil {
+		in, out := &in.Jitter, &out.Jitter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BlackoutWindows != nil {
+		in, out := &in.BlackoutWindows, &out.BlackoutWindows
+		*out = make([]BlackoutWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeDates != nil {
+		in, out := &in.ExcludeDates, &out.ExcludeDates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CalendarRefs != nil {
+		in, out := &in.CalendarRefs, &out.CalendarRefs
+		*out = make([]CalendarReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreviewCount != nil {
+		in, out := &in.PreviewCount, &out.PreviewCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetRef != nil {
+		in, out := &in.TargetRef, &out.TargetRef
+		*out = new(TargetRef)
+		**out = **in
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]RestartStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RolloutTimeout != nil {
+		in, out := &in.RolloutTimeout, &out.RolloutTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(RestartHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]RestartWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HealthGate != nil {
+		in, out := &in.HealthGate, &out.HealthGate
+		*out = new(HealthGate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 func (in *RestartScheduleSpec) DeepCopy() *RestartScheduleSpec {
@@ -84,6 +819,13 @@ func (in *RestartScheduleStatus) DeepCopyInto(out *RestartScheduleStatus) {
 		in, out := &in.NextScheduledTime, &out.NextScheduledTime
 		*out = (*in).DeepCopy()
 	}
+	if in.UpcomingRuns != nil {
+		in, out := &in.UpcomingRuns, &out.UpcomingRuns
+		*out = make([]UpcomingRun, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -91,6 +833,23 @@ func (in *RestartScheduleStatus) DeepCopyInto(out *RestartScheduleStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastExecution != nil {
+		in, out := &in.LastExecution, &out.LastExecution
+		*out = new(RestartExecution)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(TargetStatus)
+		**out = **in
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]ExecutionSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 func (in *RestartScheduleStatus) DeepCopy() *RestartScheduleStatus {
@@ -102,6 +861,189 @@ func (in *RestartScheduleStatus) DeepCopy() *RestartScheduleStatus {
 	return out
 }
 
+func (in *RestartStage) DeepCopyInto(out *RestartStage) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+func (in *RestartStage) DeepCopy() *RestartStage {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartTargetGrant) DeepCopyInto(out *RestartTargetGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+func (in *RestartTargetGrant) DeepCopy() *RestartTargetGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartTargetGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartTargetGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartTargetGrantFrom) DeepCopyInto(out *RestartTargetGrantFrom) {
+	*out = *in
+}
+
+func (in *RestartTargetGrantFrom) DeepCopy() *RestartTargetGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartTargetGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartTargetGrantList) DeepCopyInto(out *RestartTargetGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartTargetGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartTargetGrantList) DeepCopy() *RestartTargetGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartTargetGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartTargetGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartTargetGrantSpec) DeepCopyInto(out *RestartTargetGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]RestartTargetGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]RestartTargetGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+func (in *RestartTargetGrantSpec) DeepCopy() *RestartTargetGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartTargetGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartTargetGrantTo) DeepCopyInto(out *RestartTargetGrantTo) {
+	*out = *in
+}
+
+func (in *RestartTargetGrantTo) DeepCopy() *RestartTargetGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartTargetGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartWebhook) DeepCopyInto(out *RestartWebhook) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.HeaderFrom != nil {
+		in, out := &in.HeaderFrom, &out.HeaderFrom
+		*out = new(WebhookHeaderSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *RestartWebhook) DeepCopy() *RestartWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *StageStatus) DeepCopyInto(out *StageStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+func (in *StageStatus) DeepCopy() *StageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 func (in *TargetRef) DeepCopyInto(out *TargetRef) {
 	*out = *in
 }
@@ -114,3 +1056,48 @@ func (in *TargetRef) DeepCopy() *TargetRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+}
+
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *UpcomingRun) DeepCopyInto(out *UpcomingRun) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.LatestTime != nil {
+		in, out := &in.LatestTime, &out.LatestTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+func (in *UpcomingRun) DeepCopy() *UpcomingRun {
+	if in == nil {
+		return nil
+	}
+	out := new(UpcomingRun)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *WebhookHeaderSource) DeepCopyInto(out *WebhookHeaderSource) {
+	*out = *in
+	in.SecretKeyRef.DeepCopyInto(&out.SecretKeyRef)
+}
+
+func (in *WebhookHeaderSource) DeepCopy() *WebhookHeaderSource {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookHeaderSource)
+	in.DeepCopyInto(out)
+	return out
+}
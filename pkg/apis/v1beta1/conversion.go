@@ -0,0 +1,103 @@
+package v1beta1
+
+import (
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// targetListAnnotation records that a v1alpha1 schedule lists its single
+// target in targets rather than in targetRef, so the round trip through
+// v1beta1 keeps the field it was written with.
+const targetListAnnotation = "restart-operator.k8s/v1alpha1-target-list"
+
+// ConvertTo converts the schedule to the v1alpha1 storage version.
+func (src *RestartSchedule) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.RestartSchedule)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	_, targetList := dst.Annotations[targetListAnnotation]
+	delete(dst.Annotations, targetListAnnotation)
+	if len(dst.Annotations) == 0 {
+		dst.Annotations = nil
+	}
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = v1alpha1.RestartScheduleSpec{
+		Schedule:         spec.Schedule.Cron,
+		PolicyRef:        spec.PolicyRef,
+		TimeZone:         spec.Schedule.TimeZone,
+		Jitter:           spec.Schedule.Jitter,
+		BlackoutWindows:  spec.Schedule.BlackoutWindows,
+		ExcludeDates:     spec.Schedule.ExcludeDates,
+		CalendarRefs:     spec.Schedule.CalendarRefs,
+		Suspend:          spec.Suspend,
+		DryRun:           spec.DryRun,
+		PreviewCount:     spec.PreviewCount,
+		Targets:          spec.Targets,
+		Canary:           spec.Strategy.Canary,
+		Stages:           spec.Strategy.Stages,
+		RolloutTimeout:   spec.Strategy.RolloutTimeout,
+		Hooks:            spec.Hooks,
+		Webhooks:         spec.Webhooks,
+		HealthGate:       spec.HealthGate,
+		RetryPolicy:      spec.Strategy.RetryPolicy,
+		CleanupOnDelete:  spec.CleanupOnDelete,
+		DeleteWithTarget: spec.DeleteWithTarget,
+	}
+	// A single target without a canary is what v1alpha1 calls targetRef.
+	if len(spec.Targets) == 1 && spec.Strategy.Canary == nil && !targetList {
+		dst.Spec.TargetRef = &spec.Targets[0]
+		dst.Spec.Targets = nil
+	}
+
+	src.Status.DeepCopyInto(&dst.Status)
+	return nil
+}
+
+// ConvertFrom converts the schedule from the v1alpha1 storage version.
+func (dst *RestartSchedule) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.RestartSchedule)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = RestartScheduleSpec{
+		Schedule: Schedule{
+			Cron:            spec.Schedule,
+			TimeZone:        spec.TimeZone,
+			Jitter:          spec.Jitter,
+			BlackoutWindows: spec.BlackoutWindows,
+			ExcludeDates:    spec.ExcludeDates,
+			CalendarRefs:    spec.CalendarRefs,
+		},
+		PolicyRef: spec.PolicyRef,
+		Targets:   spec.Targets,
+		Strategy: RestartStrategy{
+			Canary:         spec.Canary,
+			Stages:         spec.Stages,
+			RolloutTimeout: spec.RolloutTimeout,
+			RetryPolicy:    spec.RetryPolicy,
+		},
+		Suspend:          spec.Suspend,
+		DryRun:           spec.DryRun,
+		PreviewCount:     spec.PreviewCount,
+		Hooks:            spec.Hooks,
+		Webhooks:         spec.Webhooks,
+		HealthGate:       spec.HealthGate,
+		CleanupOnDelete:  spec.CleanupOnDelete,
+		DeleteWithTarget: spec.DeleteWithTarget,
+	}
+	if spec.TargetRef != nil {
+		dst.Spec.Targets = []TargetRef{*spec.TargetRef}
+	}
+
+	delete(dst.Annotations, targetListAnnotation)
+	if len(spec.Targets) == 1 && spec.Canary == nil {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[targetListAnnotation] = "true"
+	}
+
+	src.Status.DeepCopyInto(&dst.Status)
+	return nil
+}
@@ -0,0 +1,136 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+func TestIsConvertible(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	require.NoError(t, AddToScheme(s))
+
+	convertible, err := conversion.IsConvertible(s, &RestartSchedule{})
+	require.NoError(t, err)
+	assert.True(t, convertible)
+}
+
+func TestConvertFromV1alpha1(t *testing.T) {
+	src := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default", Labels: map[string]string{"team": "a"}},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:     "0 3 * * *",
+			TimeZone:     "Europe/Berlin",
+			Jitter:       &metav1.Duration{Duration: 10 * time.Minute},
+			ExcludeDates: []string{"2025-12-24"},
+			TargetRef:    &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+			RetryPolicy:  &v1alpha1.RetryPolicy{MaxAttempts: 3},
+			Suspend:      true,
+		},
+		Status: v1alpha1.RestartScheduleStatus{LastHandledTrigger: "now"},
+	}
+
+	var dst RestartSchedule
+	require.NoError(t, dst.ConvertFrom(src))
+
+	assert.Equal(t, "api", dst.Name)
+	assert.Equal(t, map[string]string{"team": "a"}, dst.Labels)
+	assert.Equal(t, Schedule{
+		Cron:         "0 3 * * *",
+		TimeZone:     "Europe/Berlin",
+		Jitter:       &metav1.Duration{Duration: 10 * time.Minute},
+		ExcludeDates: []string{"2025-12-24"},
+	}, dst.Spec.Schedule)
+	assert.Equal(t, []TargetRef{{Kind: "Deployment", Name: "api"}}, dst.Spec.Targets)
+	assert.Equal(t, &RetryPolicy{MaxAttempts: 3}, dst.Spec.Strategy.RetryPolicy)
+	assert.True(t, dst.Spec.Suspend)
+	assert.Equal(t, "now", dst.Status.LastHandledTrigger)
+	assert.NotContains(t, dst.Annotations, targetListAnnotation)
+
+	// The conversion must not share memory with its source.
+	dst.Spec.Targets[0].Name = "changed"
+	assert.Equal(t, "api", src.Spec.TargetRef.Name)
+}
+
+func TestConvertToV1alpha1(t *testing.T) {
+	src := &RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: RestartScheduleSpec{
+			Schedule: Schedule{Cron: "0 3 * * *", TimeZone: "Asia/Tokyo"},
+			Targets: []TargetRef{
+				{Kind: "Deployment", Name: "api"},
+				{Kind: "Deployment", Name: "worker"},
+			},
+			Strategy: RestartStrategy{
+				Canary:         &CanaryPolicy{Count: ptr.To[int32](1)},
+				RolloutTimeout: &metav1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+	}
+
+	var dst v1alpha1.RestartSchedule
+	require.NoError(t, src.ConvertTo(&dst))
+
+	assert.Equal(t, "0 3 * * *", dst.Spec.Schedule)
+	assert.Equal(t, "Asia/Tokyo", dst.Spec.TimeZone)
+	assert.Nil(t, dst.Spec.TargetRef)
+	assert.Equal(t, src.Spec.Targets, dst.Spec.Targets)
+	assert.Equal(t, ptr.To[int32](1), dst.Spec.Canary.Count)
+	assert.Equal(t, 5*time.Minute, dst.Spec.RolloutTimeout.Duration)
+
+	// A single target without a canary becomes targetRef.
+	src.Spec.Targets = src.Spec.Targets[:1]
+	src.Spec.Strategy.Canary = nil
+	require.NoError(t, src.ConvertTo(&dst))
+	assert.Equal(t, &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"}, dst.Spec.TargetRef)
+	assert.Nil(t, dst.Spec.Targets)
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for name, spec := range map[string]v1alpha1.RestartScheduleSpec{
+		"targetRef": {
+			Schedule:  "0 3 * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+		"single target in targets": {
+			Schedule: "0 3 * * *",
+			Targets:  []v1alpha1.TargetRef{{Kind: "Deployment", Name: "api"}},
+		},
+		"stages from policy": {
+			PolicyRef: &v1alpha1.PolicyReference{Kind: "ClusterRestartPolicy", Name: "nightly"},
+			Stages: []v1alpha1.RestartStage{
+				{Name: "backend", Targets: []v1alpha1.TargetRef{{Kind: "StatefulSet", Name: "db"}}},
+				{Name: "frontend", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "web"}}},
+			},
+			BlackoutWindows: []v1alpha1.BlackoutWindow{
+				{Name: "weekend", Schedule: "0 0 * * 6", Duration: metav1.Duration{Duration: 48 * time.Hour}},
+			},
+			CalendarRefs: []v1alpha1.CalendarReference{{Name: "holidays"}},
+			Webhooks:     []v1alpha1.RestartWebhook{{Name: "audit", URL: "https://audit.example.com"}},
+			PreviewCount: ptr.To[int32](5),
+			DryRun:       true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := &v1alpha1.RestartSchedule{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Spec:       spec,
+			}
+
+			var beta RestartSchedule
+			require.NoError(t, beta.ConvertFrom(original))
+			var roundTripped v1alpha1.RestartSchedule
+			require.NoError(t, beta.ConvertTo(&roundTripped))
+
+			assert.Equal(t, original, &roundTripped)
+		})
+	}
+}
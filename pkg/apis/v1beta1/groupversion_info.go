@@ -0,0 +1,18 @@
+// Package v1beta1 holds the v1beta1 API of RestartSchedules. It is served
+// next to v1alpha1, which remains the storage version, and converted from and
+// to it by the conversion webhook of the operator. Types that are unchanged
+// from v1alpha1 are aliases of their v1alpha1 counterparts.
+// +kubebuilder:object:generate=true
+// +groupName=restart-operator.k8s
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	GroupVersion  = schema.GroupVersion{Group: "restart-operator.k8s", Version: "v1beta1"}
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+	AddToScheme   = SchemeBuilder.AddToScheme
+)
@@ -0,0 +1,177 @@
+package v1beta1
+
+import (
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,shortName=rs,categories=restart-operator
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule.cron`
+// +kubebuilder:printcolumn:name="Time-Zone",type=string,JSONPath=`.spec.schedule.timeZone`
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Last-Restart",type=string,JSONPath=`.status.lastSuccessfulTime`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+type RestartSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="has(self.targets) != (has(self.strategy) && has(self.strategy.stages))",message="exactly one of targets or strategy.stages must be set"
+	// +kubebuilder:validation:XValidation:rule="!has(self.strategy) || !has(self.strategy.canary) || has(self.targets)",message="strategy.canary requires targets"
+	// +kubebuilder:validation:XValidation:rule="(has(self.schedule) && has(self.schedule.cron)) || has(self.policyRef)",message="schedule.cron is required unless policyRef is set"
+	Spec   RestartScheduleSpec   `json:"spec,omitempty"`
+	Status RestartScheduleStatus `json:"status,omitempty"`
+}
+
+type RestartScheduleSpec struct {
+	// Schedule says when the targets are restarted.
+	// +optional
+	Schedule Schedule `json:"schedule,omitempty"`
+
+	// PolicyRef fills in the fields left unset here from a RestartPolicy in
+	// the namespace of the schedule or from a ClusterRestartPolicy.
+	// +optional
+	PolicyRef *PolicyReference `json:"policyRef,omitempty"`
+
+	// Targets are the workloads restarted together at every run.
+	// +kubebuilder:validation:MinItems=1
+	// +optional
+	Targets []TargetRef `json:"targets,omitempty"`
+
+	// Strategy says how the targets are restarted.
+	// +optional
+	Strategy RestartStrategy `json:"strategy,omitempty"`
+
+	// Suspend stops scheduled runs. Runs requested with the trigger
+	// annotation still start.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun evaluates the gates and targets of every run and sends the
+	// restart patches as server-side dry runs, so nothing is restarted.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// PreviewCount is the number of upcoming runs listed in
+	// status.upcomingRuns. Defaults to 3; 0 turns the preview off.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=20
+	// +optional
+	PreviewCount *int32 `json:"previewCount,omitempty"`
+
+	// Hooks are Jobs run before and after every scheduled restart.
+	// +optional
+	Hooks *RestartHooks `json:"hooks,omitempty"`
+
+	// Webhooks are called over HTTP before and after every scheduled restart.
+	// +optional
+	Webhooks []RestartWebhook `json:"webhooks,omitempty"`
+
+	// HealthGate must report the workloads as healthy before a restart starts
+	// and keep doing so for a soak period after the rollout has completed.
+	// +optional
+	HealthGate *HealthGate `json:"healthGate,omitempty"`
+
+	// CleanupOnDelete removes the annotations the schedule wrote to the pod
+	// templates of its targets when the schedule is deleted. Removing them
+	// rolls the targets out once more.
+	// +optional
+	CleanupOnDelete bool `json:"cleanupOnDelete,omitempty"`
+
+	// DeleteWithTarget makes the targets in the namespace of the schedule its
+	// owners, so the schedule is garbage-collected once all of them have
+	// been deleted.
+	// +optional
+	DeleteWithTarget bool `json:"deleteWithTarget,omitempty"`
+}
+
+// Schedule holds the cron expression of the restarts along with the time
+// zone it is evaluated in and the times at which runs are skipped.
+type Schedule struct {
+	// Cron is the cron expression of the restarts. It may be omitted when
+	// the referenced policy provides one.
+	// +kubebuilder:validation:Pattern=`^(\d+|\*)(/\d+)?(\s+(\d+|\*)(/\d+)?){4}$`
+	// +optional
+	Cron string `json:"cron,omitempty"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in, e.g.
+	// Europe/Berlin. The time zone of the operator is used when it is empty.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Jitter delays every run by a random duration of up to the given
+	// length, so schedules sharing a cron expression do not all restart at
+	// the same moment.
+	// +optional
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+
+	// BlackoutWindows are periods in which scheduled restarts are skipped.
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
+
+	// ExcludeDates are days, in the format YYYY-MM-DD and in the time zone
+	// of the schedule, on which scheduled runs are skipped.
+	// +kubebuilder:validation:items:Pattern=`^\d{4}-\d{2}-\d{2}$`
+	// +optional
+	ExcludeDates []string `json:"excludeDates,omitempty"`
+
+	// CalendarRefs name RestartCalendars listing further days to skip.
+	// +optional
+	CalendarRefs []CalendarReference `json:"calendarRefs,omitempty"`
+}
+
+// RestartStrategy says how the targets of a run are restarted. Without
+// canary or stages, all targets are restarted at once.
+type RestartStrategy struct {
+	// Canary restarts part of the targets first and only restarts the rest
+	// once those have rolled out and stayed healthy for the soak period.
+	// +optional
+	Canary *CanaryPolicy `json:"canary,omitempty"`
+
+	// Stages restarts several groups of workloads in order, instead of
+	// targets. Each stage waits until the rollout of all its targets has
+	// completed before the next one starts, and a failing stage aborts the
+	// rest of the sequence.
+	// +kubebuilder:validation:MinItems=1
+	// +optional
+	Stages []RestartStage `json:"stages,omitempty"`
+
+	// RolloutTimeout bounds how long a stage waits for its targets to finish
+	// rolling out. Defaults to 10m.
+	// +optional
+	RolloutTimeout *metav1.Duration `json:"rolloutTimeout,omitempty"`
+
+	// RetryPolicy controls how a failed restart is retried before the run is
+	// given up until the next scheduled time.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+}
+
+type (
+	TargetRef             = v1alpha1.TargetRef
+	PolicyReference       = v1alpha1.PolicyReference
+	CalendarReference     = v1alpha1.CalendarReference
+	BlackoutWindow        = v1alpha1.BlackoutWindow
+	CanaryPolicy          = v1alpha1.CanaryPolicy
+	RestartStage          = v1alpha1.RestartStage
+	RetryPolicy           = v1alpha1.RetryPolicy
+	RestartHooks          = v1alpha1.RestartHooks
+	RestartWebhook        = v1alpha1.RestartWebhook
+	HealthGate            = v1alpha1.HealthGate
+	RestartScheduleStatus = v1alpha1.RestartScheduleStatus
+)
+
+// +kubebuilder:object:root=true
+
+type RestartScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestartSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RestartSchedule{}, &RestartScheduleList{})
+}
@@ -0,0 +1,177 @@
+//go:build !ignore_autogenerated
+
+package v1beta1
+
+import (
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *RestartSchedule) DeepCopyInto(out *RestartSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+func (in *RestartSchedule) DeepCopy() *RestartSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartScheduleList) DeepCopyInto(out *RestartScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestartSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+func (in *RestartScheduleList) DeepCopy() *RestartScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *RestartScheduleSpec) DeepCopyInto(out *RestartScheduleSpec) {
+	*out = *in
+	in.Schedule.DeepCopyInto(&out.Schedule)
+	if in.PolicyRef != nil {
+		in, out := &in.PolicyRef, &out.PolicyRef
+		*out = new(v1alpha1.PolicyReference)
+		**out = **in
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]v1alpha1.TargetRef, len(*in))
+		copy(*out, *in)
+	}
+	in.Strategy.DeepCopyInto(&out.Strategy)
+	if in.PreviewCount != nil {
+		in, out := &in.PreviewCount, &out.PreviewCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(v1alpha1.RestartHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]v1alpha1.RestartWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HealthGate != nil {
+		in, out := &in.HealthGate, &out.HealthGate
+		*out = new(v1alpha1.HealthGate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *RestartScheduleSpec) DeepCopy() *RestartScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *RestartStrategy) DeepCopyInto(out *RestartStrategy) {
+	*out = *in
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(v1alpha1.CanaryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]v1alpha1.RestartStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RolloutTimeout != nil {
+		in, out := &in.RolloutTimeout, &out.RolloutTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(v1alpha1.RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+func (in *RestartStrategy) DeepCopy() *RestartStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RestartStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *Schedule) DeepCopyInto(out *Schedule) {
+	*out = *in
+	if in.Jitter != nil {
+		in, out := &in.Jitter, &out.Jitter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BlackoutWindows != nil {
+		in, out := &in.BlackoutWindows, &out.BlackoutWindows
+		*out = make([]v1alpha1.BlackoutWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeDates != nil {
+		in, out := &in.ExcludeDates, &out.ExcludeDates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CalendarRefs != nil {
+		in, out := &in.CalendarRefs, &out.CalendarRefs
+		*out = make([]v1alpha1.CalendarReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+func (in *Schedule) DeepCopy() *Schedule {
+	if in == nil {
+		return nil
+	}
+	out := new(Schedule)
+	in.DeepCopyInto(out)
+	return out
+}
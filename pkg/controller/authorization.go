@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// forbiddenError is returned when a RestartSchedule targets a workload in
+// another namespace that no RestartTargetGrant lets it restart.
+type forbiddenError struct {
+	ref       v1alpha1.TargetRef
+	namespace string
+}
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("%s %s/%s is in another namespace and no RestartTargetGrant allows restarting it",
+		e.ref.Kind, e.namespace, e.ref.Name)
+}
+
+// authorizeTargets checks that the schedule may restart every one of its
+// targets. Targets in the namespace of the schedule are always allowed;
+// targets elsewhere need a RestartTargetGrant in their namespace unless
+// cross-namespace targets are allowed for all schedules. Cluster-scoped
+// schedules can only be created by cluster administrators and are not
+// restricted.
+func (r *RestartScheduleReconciler) authorizeTargets(ctx context.Context, schedule scheduleObject) error {
+	if r.AllowCrossNamespaceTargets || schedule.GetNamespace() == "" {
+		return nil
+	}
+
+	grants := make(map[string][]v1alpha1.RestartTargetGrant)
+	for _, ref := range scheduleTargets(schedule.GetScheduleSpec()) {
+		namespace := targetNamespace(ref, schedule.GetNamespace())
+		if namespace == schedule.GetNamespace() {
+			continue
+		}
+
+		if _, ok := grants[namespace]; !ok {
+			var list v1alpha1.RestartTargetGrantList
+			if err := r.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+				return fmt.Errorf("failed to list RestartTargetGrants in %s: %w", namespace, err)
+			}
+			grants[namespace] = list.Items
+		}
+		if !grantsAllow(grants[namespace], schedule.GetNamespace(), ref, r.targetKinds()) {
+			return &forbiddenError{ref: ref, namespace: namespace}
+		}
+	}
+	return nil
+}
+
+func grantsAllow(grants []v1alpha1.RestartTargetGrant, from string, ref v1alpha1.TargetRef, kinds *TargetKindRegistry) bool {
+	for _, grant := range grants {
+		if grantAllows(&grant.Spec, from, ref, kinds) {
+			return true
+		}
+	}
+	return false
+}
+
+func grantAllows(grant *v1alpha1.RestartTargetGrantSpec, from string, ref v1alpha1.TargetRef, kinds *TargetKindRegistry) bool {
+	fromAllowed := false
+	for _, f := range grant.From {
+		if f.Namespace == from {
+			fromAllowed = true
+			break
+		}
+	}
+	if !fromAllowed {
+		return false
+	}
+
+	if len(grant.To) == 0 {
+		return true
+	}
+	// A target whose kind cannot be resolved is only allowed by grants for
+	// every workload.
+	target, _, err := kinds.Lookup(ref)
+	if err != nil {
+		return false
+	}
+	for _, to := range grant.To {
+		if to.Kind != target.Kind || (to.Name != "" && to.Name != ref.Name) {
+			continue
+		}
+		group := to.Group
+		if group == "" {
+			gvk, _, err := kinds.Lookup(v1alpha1.TargetRef{Kind: to.Kind})
+			if err != nil {
+				continue
+			}
+			group = gvk.Group
+		}
+		if group == target.Group {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulesForGrant maps a RestartTargetGrant to the RestartSchedules in the
+// namespaces it grants access to, so they are re-validated when it changes.
+func (r *RestartScheduleReconciler) schedulesForGrant(ctx context.Context, obj client.Object) []reconcile.Request {
+	grant, ok := obj.(*v1alpha1.RestartTargetGrant)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, from := range grant.Spec.From {
+		var schedules v1alpha1.RestartScheduleList
+		if err := r.List(ctx, &schedules, client.InNamespace(from.Namespace)); err != nil {
+			r.Log.Error(err, "Failed to list RestartSchedules for RestartTargetGrant",
+				"grant", client.ObjectKeyFromObject(grant))
+			continue
+		}
+		for _, schedule := range schedules.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: schedule.Name, Namespace: schedule.Namespace},
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func crossNamespaceSchedule() *v1alpha1.RestartSchedule {
+	return &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "ops"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 3 * * *",
+			TargetRef: &v1alpha1.TargetRef{
+				Kind:      "Deployment",
+				Name:      "payments-api",
+				Namespace: "payments",
+			},
+		},
+	}
+}
+
+func reconcileValidCondition(t *testing.T, reconciler *RestartScheduleReconciler) *metav1.Condition {
+	t.Helper()
+	key := types.NamespacedName{Name: "payments", Namespace: "ops"}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	condition := meta.FindStatusCondition(updated.Status.Conditions, "Valid")
+	require.NotNil(t, condition)
+	return condition
+}
+
+func TestCrossNamespaceTargetWithoutGrantIsForbidden(t *testing.T) {
+	reconciler := newTestReconciler(crossNamespaceSchedule())
+
+	condition := reconcileValidCondition(t, reconciler)
+	assert.Equal(t, "Valid", condition.Type)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Forbidden", condition.Reason)
+	assert.Contains(t, condition.Message, "Deployment payments/payments-api")
+	assert.Empty(t, reconciler.scheduleIDs)
+}
+
+func TestCrossNamespaceTargetWithGrant(t *testing.T) {
+	grant := &v1alpha1.RestartTargetGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-ops", Namespace: "payments"},
+		Spec: v1alpha1.RestartTargetGrantSpec{
+			From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+			To:   []v1alpha1.RestartTargetGrantTo{{Kind: "Deployment", Name: "payments-api"}},
+		},
+	}
+	reconciler := newTestReconciler(crossNamespaceSchedule(), grant)
+
+	condition := reconcileValidCondition(t, reconciler)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Len(t, reconciler.scheduleIDs, 1)
+
+	requests := reconciler.schedulesForGrant(context.Background(), grant)
+	assert.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Name: "payments", Namespace: "ops"}}}, requests)
+}
+
+func TestCrossNamespaceTargetsAllowedByFlag(t *testing.T) {
+	reconciler := newTestReconciler(crossNamespaceSchedule())
+	reconciler.AllowCrossNamespaceTargets = true
+
+	condition := reconcileValidCondition(t, reconciler)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+}
+
+func TestGrantAllows(t *testing.T) {
+	api := v1alpha1.TargetRef{Kind: "Deployment", Name: "api"}
+
+	tests := []struct {
+		name    string
+		grant   v1alpha1.RestartTargetGrantSpec
+		from    string
+		target  v1alpha1.TargetRef
+		allowed bool
+	}{
+		{
+			name:    "any target",
+			grant:   v1alpha1.RestartTargetGrantSpec{From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}}},
+			from:    "ops",
+			allowed: true,
+		},
+		{
+			name:    "other namespace",
+			grant:   v1alpha1.RestartTargetGrantSpec{From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}}},
+			from:    "team-a",
+			allowed: false,
+		},
+		{
+			name: "kind",
+			grant: v1alpha1.RestartTargetGrantSpec{
+				From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+				To:   []v1alpha1.RestartTargetGrantTo{{Kind: "Deployment"}},
+			},
+			from:    "ops",
+			allowed: true,
+		},
+		{
+			name: "other workload",
+			grant: v1alpha1.RestartTargetGrantSpec{
+				From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+				To:   []v1alpha1.RestartTargetGrantTo{{Kind: "Deployment", Name: "worker"}, {Kind: "StatefulSet"}},
+			},
+			from:    "ops",
+			allowed: false,
+		},
+		{
+			name: "group",
+			grant: v1alpha1.RestartTargetGrantSpec{
+				From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+				To:   []v1alpha1.RestartTargetGrantTo{{Group: "apps", Kind: "Deployment"}},
+			},
+			from:    "ops",
+			allowed: true,
+		},
+		{
+			name: "same kind in another group",
+			grant: v1alpha1.RestartTargetGrantSpec{
+				From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+				To:   []v1alpha1.RestartTargetGrantTo{{Kind: "Deployment"}},
+			},
+			from:    "ops",
+			target:  v1alpha1.TargetRef{APIVersion: "example.com/v1", Kind: "Deployment", Name: "api"},
+			allowed: false,
+		},
+		{
+			name: "kind that is not built in",
+			grant: v1alpha1.RestartTargetGrantSpec{
+				From: []v1alpha1.RestartTargetGrantFrom{{Namespace: "ops"}},
+				To:   []v1alpha1.RestartTargetGrantTo{{Group: "argoproj.io", Kind: "Rollout"}},
+			},
+			from:    "ops",
+			target:  v1alpha1.TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "api"},
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target
+			if target.Kind == "" {
+				target = api
+			}
+			assert.Equal(t, tt.allowed, grantAllows(&tt.grant, tt.from, target, NewTargetKindRegistry()))
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// calendarIndexKey indexes schedules by the names of the RestartCalendars
+// they reference.
+const calendarIndexKey = "spec.calendarRefs"
+
+// calendarConfigMapIndexKey indexes RestartCalendars by the namespace and
+// name of the ConfigMap they read.
+const calendarConfigMapIndexKey = "spec.configMapRef"
+
+// defaultCalendarKey is the ConfigMap key read when a calendar does not name
+// one.
+const defaultCalendarKey = "calendar.ics"
+
+// dateLayout is the format of excluded dates.
+const dateLayout = "2006-01-02"
+
+// maxEventDays bounds the days a single iCalendar event may span, so a
+// malformed end date cannot blow up the set of holidays.
+const maxEventDays = 366
+
+// indexCalendarRefs is the field indexer of calendarIndexKey.
+func indexCalendarRefs(obj client.Object) []string {
+	schedule, ok := obj.(scheduleObject)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, ref := range schedule.GetScheduleSpec().CalendarRefs {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+// indexCalendarConfigMap is the field indexer of calendarConfigMapIndexKey.
+func indexCalendarConfigMap(obj client.Object) []string {
+	calendar, ok := obj.(*v1alpha1.RestartCalendar)
+	if !ok || calendar.Spec.ConfigMapRef == nil {
+		return nil
+	}
+	return []string{calendar.Spec.ConfigMapRef.Namespace + "/" + calendar.Spec.ConfigMapRef.Name}
+}
+
+// holidays are the days on which the scheduled runs of a schedule are
+// skipped, keyed by date, with the name reported for the day.
+type holidays struct {
+	dates map[string]string
+	// yearly holds the recurring days, keyed by month and day.
+	yearly map[string][]yearlyHoliday
+}
+
+// yearlyHoliday is a day that recurs every year from the year of its first
+// occurrence on.
+type yearlyHoliday struct {
+	name  string
+	since int
+}
+
+func newHolidays() *holidays {
+	return &holidays{dates: map[string]string{}, yearly: map[string][]yearlyHoliday{}}
+}
+
+// add marks the day of t as a holiday. A yearly holiday also marks the same
+// day of every later year, but not of the years before t.
+func (h *holidays) add(t time.Time, name string, yearly bool) {
+	if yearly {
+		day := t.Format("01-02")
+		h.yearly[day] = append(h.yearly[day], yearlyHoliday{name: name, since: t.Year()})
+		return
+	}
+	h.dates[t.Format(dateLayout)] = name
+}
+
+// lookup returns the name of the holiday on the day of t in the given
+// location. The name of an excluded date without one is the date itself.
+func (h *holidays) lookup(t time.Time, location *time.Location) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	t = t.In(location)
+	if name, ok := h.dates[t.Format(dateLayout)]; ok {
+		return name, true
+	}
+	for _, holiday := range h.yearly[t.Format("01-02")] {
+		if t.Year() >= holiday.since {
+			return holiday.name, true
+		}
+	}
+	return "", false
+}
+
+// scheduleLocation returns the time zone the cron expression of the spec is
+// evaluated in.
+func scheduleLocation(spec *v1alpha1.RestartScheduleSpec) (*time.Location, error) {
+	if spec.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(spec.TimeZone)
+}
+
+// parseExcludeDates checks that every excluded date of the spec is a valid
+// date.
+func parseExcludeDates(spec *v1alpha1.RestartScheduleSpec) error {
+	for _, date := range spec.ExcludeDates {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("invalid excluded date %q: must be in the format YYYY-MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// loadHolidays collects the excluded dates of the spec and the days of the
+// calendars it references. It returns nil if the spec excludes no days.
+func (r *RestartScheduleReconciler) loadHolidays(ctx context.Context, spec *v1alpha1.RestartScheduleSpec) (*holidays, error) {
+	if len(spec.ExcludeDates) == 0 && len(spec.CalendarRefs) == 0 {
+		return nil, nil
+	}
+
+	h := newHolidays()
+	for _, date := range spec.ExcludeDates {
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded date %q: must be in the format YYYY-MM-DD", date)
+		}
+		h.add(t, date, false)
+	}
+
+	for _, ref := range spec.CalendarRefs {
+		var calendar v1alpha1.RestartCalendar
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, &calendar); err != nil {
+			return nil, fmt.Errorf("failed to get RestartCalendar %s: %w", ref.Name, err)
+		}
+		for _, date := range calendar.Spec.Dates {
+			t, err := time.Parse(dateLayout, date.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q in RestartCalendar %s", date.Date, ref.Name)
+			}
+			name := date.Name
+			if name == "" {
+				name = date.Date
+			}
+			h.add(t, name, false)
+		}
+
+		cmRef := calendar.Spec.ConfigMapRef
+		if cmRef == nil {
+			continue
+		}
+		key := cmRef.Key
+		if key == "" {
+			key = defaultCalendarKey
+		}
+		var configMap corev1.ConfigMap
+		cmKey := types.NamespacedName{Name: cmRef.Name, Namespace: cmRef.Namespace}
+		if err := r.Get(ctx, cmKey, &configMap); err != nil {
+			return nil, fmt.Errorf("failed to get ConfigMap %s of RestartCalendar %s: %w", cmKey, ref.Name, err)
+		}
+		data, ok := configMap.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %s of RestartCalendar %s has no key %q", cmKey, ref.Name, key)
+		}
+		if err := parseICalendar(data, h); err != nil {
+			return nil, fmt.Errorf("invalid iCalendar data in ConfigMap %s: %w", cmKey, err)
+		}
+	}
+	return h, nil
+}
+
+// checkHolidays returns a skipError with the Holiday reason if today, in the
+// time zone of the spec, is one of its holidays. On failure it returns the
+// condition reason to report along with the error.
+func (r *RestartScheduleReconciler) checkHolidays(ctx context.Context, spec *v1alpha1.RestartScheduleSpec) (string, error) {
+	holidays, err := r.loadHolidays(ctx, spec)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "CalendarNotFound", err
+		}
+		return "CalendarFailed", err
+	}
+	location, err := scheduleLocation(spec)
+	if err != nil {
+		return "InvalidSchedule", err
+	}
+	if name, ok := holidays.lookup(time.Now(), location); ok {
+		return "Holiday", &skipError{
+			reason:  "Holiday",
+			message: fmt.Sprintf("Restart skipped on holiday %q", name),
+		}
+	}
+	return "", nil
+}
+
+// icsEvent holds the properties of a VEVENT that matter for holidays.
+type icsEvent struct {
+	summary   string
+	start     time.Time
+	end       time.Time
+	yearly    bool
+	cancelled bool
+}
+
+// parseICalendar adds the days covered by the VEVENTs of the iCalendar data
+// to h. Only the calendar date of DTSTART and DTEND is used, as written in
+// the data; an event ends before the day of DTEND unless DTEND has a time of
+// day after midnight. The only recurrence rule supported is FREQ=YEARLY
+// without any other part.
+func parseICalendar(data string, h *holidays) error {
+	var event *icsEvent
+	for _, line := range unfoldICalendar(data) {
+		name, params, value, ok := parseICalendarLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event = &icsEvent{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if event == nil {
+				return fmt.Errorf("END:VEVENT without BEGIN:VEVENT")
+			}
+			if err := addICalendarEvent(event, h); err != nil {
+				return err
+			}
+			event = nil
+		case event == nil:
+			// Properties outside of events are ignored.
+		case name == "SUMMARY":
+			event.summary = unescapeICalendarText(value)
+		case name == "STATUS":
+			event.cancelled = strings.EqualFold(value, "CANCELLED")
+		case name == "DTSTART" || name == "DTEND":
+			t, hasTime, err := parseICalendarTime(value, params)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			if name == "DTSTART" {
+				event.start = t
+			} else {
+				// An end with a time of day after midnight still covers
+				// its day.
+				if hasTime && (t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0) {
+					t = t.AddDate(0, 0, 1)
+				}
+				event.end = t
+			}
+		case name == "RRULE":
+			// Any other part, such as UNTIL, COUNT or BYDAY, would change
+			// the days the event recurs on.
+			if !strings.EqualFold(strings.TrimSuffix(value, ";"), "FREQ=YEARLY") {
+				return fmt.Errorf("unsupported RRULE %q: only FREQ=YEARLY is supported", value)
+			}
+			event.yearly = true
+		}
+	}
+	if event != nil {
+		return fmt.Errorf("BEGIN:VEVENT without END:VEVENT")
+	}
+	return nil
+}
+
+// addICalendarEvent adds the days from the start of the event up to, but not
+// including, its end to h. An event without an end covers its start day.
+func addICalendarEvent(event *icsEvent, h *holidays) error {
+	if event.cancelled {
+		return nil
+	}
+	if event.start.IsZero() {
+		return fmt.Errorf("VEVENT %q has no DTSTART", event.summary)
+	}
+	start := truncateDay(event.start)
+	end := truncateDay(event.end)
+	if !end.After(start) {
+		end = start.AddDate(0, 0, 1)
+	}
+	name := event.summary
+	if name == "" {
+		name = start.Format(dateLayout)
+	}
+	for day, i := start, 0; day.Before(end); day, i = day.AddDate(0, 0, 1), i+1 {
+		if i == maxEventDays {
+			return fmt.Errorf("VEVENT %q spans more than %d days", event.summary, maxEventDays)
+		}
+		h.add(day, name, event.yearly)
+	}
+	return nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// unfoldICalendar splits the data into content lines, joining the lines
+// continued with a leading space or tab.
+func unfoldICalendar(data string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICalendarLine splits a content line into its upper-cased name, its
+// parameters and its value.
+func parseICalendarLine(line string) (name string, params map[string]string, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+	parts := strings.Split(head, ";")
+	params = map[string]string{}
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value), true
+}
+
+// parseICalendarTime parses a DATE or DATE-TIME value and reports whether it
+// has a time of day.
+func parseICalendarTime(value string, params map[string]string) (time.Time, bool, error) {
+	if strings.EqualFold(params["VALUE"], "DATE") || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		return t, false, err
+	}
+	t, err := time.Parse("20060102T150405", strings.TrimSuffix(value, "Z"))
+	return t, true, err
+}
+
+// unescapeICalendarText resolves the escapes of a TEXT value.
+func unescapeICalendarText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// schedulesForCalendar returns a map function enqueuing the schedules of the
+// given list type that reference a changed RestartCalendar.
+func (r *RestartScheduleReconciler) schedulesForCalendar(newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		list := newList()
+		if err := r.List(ctx, list, client.MatchingFields{calendarIndexKey: obj.GetName()}); err != nil {
+			r.Log.Error(err, "Failed to list schedules for calendar", "calendar", obj.GetName())
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, schedule := range scheduleItems(list) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: schedule.GetName(), Namespace: schedule.GetNamespace()},
+			})
+		}
+		return requests
+	}
+}
+
+// calendarsForConfigMap returns the RestartCalendars reading the ConfigMap.
+func (r *RestartScheduleReconciler) calendarsForConfigMap(ctx context.Context, obj client.Object) ([]v1alpha1.RestartCalendar, error) {
+	var calendars v1alpha1.RestartCalendarList
+	err := r.List(ctx, &calendars, client.MatchingFields{
+		calendarConfigMapIndexKey: obj.GetNamespace() + "/" + obj.GetName(),
+	})
+	return calendars.Items, err
+}
+
+// referencedByCalendar reports whether a RestartCalendar reads the ConfigMap.
+// It filters the ConfigMap events before they are mapped to schedules.
+func (r *RestartScheduleReconciler) referencedByCalendar(obj client.Object) bool {
+	calendars, err := r.calendarsForConfigMap(context.Background(), obj)
+	if err != nil {
+		r.Log.Error(err, "Failed to list calendars for ConfigMap", "configmap", client.ObjectKeyFromObject(obj))
+		return false
+	}
+	return len(calendars) > 0
+}
+
+// schedulesForConfigMap returns a map function enqueuing the schedules of the
+// given list type that reference a RestartCalendar reading a changed
+// ConfigMap.
+func (r *RestartScheduleReconciler) schedulesForConfigMap(newList func() client.ObjectList) handler.MapFunc {
+	forCalendar := r.schedulesForCalendar(newList)
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		calendars, err := r.calendarsForConfigMap(ctx, obj)
+		if err != nil {
+			r.Log.Error(err, "Failed to list calendars for ConfigMap", "configmap", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range calendars {
+			requests = append(requests, forCalendar(ctx, &calendars[i])...)
+		}
+		return requests
+	}
+}
+
+// watchCalendarConfigMaps adds a metadata-only watch on ConfigMaps that
+// enqueues the schedules of the given list type whose calendars read a
+// changed ConfigMap. Only the metadata is cached; the data is still read
+// directly when a calendar is loaded.
+func (r *RestartScheduleReconciler) watchCalendarConfigMaps(bldr *builder.Builder, newList func() client.ObjectList) *builder.Builder {
+	return bldr.Watches(&corev1.ConfigMap{},
+		handler.EnqueueRequestsFromMapFunc(r.schedulesForConfigMap(newList)),
+		builder.OnlyMetadata,
+		builder.WithPredicates(predicate.NewPredicateFuncs(r.referencedByCalendar)))
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testICalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Operations//Calendar//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:new-year@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20250101\r\n" +
+	"DTEND;VALUE=DATE:20250102\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"SUMMARY:New Year's Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holidays@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20251224\r\n" +
+	"DTEND;VALUE=DATE:20251227\r\n" +
+	"SUMMARY:Christmas\\, reduced\r\n" +
+	"  on-call coverage\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:migration@example.com\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20250315T220000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20250316T060000\r\n" +
+	"SUMMARY:Datacenter migration\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20250501\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"SUMMARY:Cancelled freeze\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICalendar(t *testing.T) {
+	h := newHolidays()
+	require.NoError(t, parseICalendar(testICalendar, h))
+
+	for date, want := range map[string]string{
+		"2025-01-01": "New Year's Day",
+		"2031-01-01": "New Year's Day",
+		"2025-12-24": "Christmas, reduced on-call coverage",
+		"2025-12-26": "Christmas, reduced on-call coverage",
+		"2025-03-15": "Datacenter migration",
+		"2025-03-16": "Datacenter migration",
+	} {
+		day, err := time.Parse(dateLayout, date)
+		require.NoError(t, err)
+		name, ok := h.lookup(day.Add(12*time.Hour), time.UTC)
+		assert.True(t, ok, date)
+		assert.Equal(t, want, name, date)
+	}
+	// Yearly events only recur from the year of their DTSTART on.
+	for _, date := range []string{"2024-01-01", "2025-01-02", "2025-12-27", "2025-03-17", "2025-05-01"} {
+		day, err := time.Parse(dateLayout, date)
+		require.NoError(t, err)
+		_, ok := h.lookup(day.Add(12*time.Hour), time.UTC)
+		assert.False(t, ok, date)
+	}
+
+	assert.Error(t, parseICalendar("BEGIN:VEVENT\nDTSTART:20250101\nRRULE:FREQ=WEEKLY\nEND:VEVENT\n", newHolidays()))
+	for _, rule := range []string{"FREQ=YEARLY;UNTIL=20260101", "FREQ=YEARLY;COUNT=2", "FREQ=YEARLY;BYDAY=1MO", "FREQ=YEARLY;BYMONTH=12", "FREQ=YEARLY;INTERVAL=2"} {
+		err := parseICalendar("BEGIN:VEVENT\nDTSTART:20250101\nRRULE:"+rule+"\nEND:VEVENT\n", newHolidays())
+		assert.Error(t, err, rule)
+	}
+	assert.Error(t, parseICalendar("BEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT\n", newHolidays()))
+	assert.Error(t, parseICalendar("BEGIN:VEVENT\nDTSTART:2025-01-01\nEND:VEVENT\n", newHolidays()))
+	assert.Error(t, parseICalendar("BEGIN:VEVENT\nDTSTART:20250101\n", newHolidays()))
+}
+
+func TestHolidaysUseScheduleTimeZone(t *testing.T) {
+	h := newHolidays()
+	h.add(time.Date(2025, 12, 25, 0, 0, 0, 0, time.UTC), "Christmas", false)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// 20:00 UTC on 24 December is already the 25th in Tokyo.
+	t0 := time.Date(2025, 12, 24, 20, 0, 0, 0, time.UTC)
+	_, ok := h.lookup(t0, time.UTC)
+	assert.False(t, ok)
+	name, ok := h.lookup(t0, tokyo)
+	assert.True(t, ok)
+	assert.Equal(t, "Christmas", name)
+
+	var none *holidays
+	_, ok = none.lookup(t0, tokyo)
+	assert.False(t, ok)
+}
+
+func TestUpcomingRunsSkipHolidays(t *testing.T) {
+	spec := &v1alpha1.RestartScheduleSpec{
+		Schedule:     "0 3 * * *",
+		TimeZone:     "Asia/Tokyo",
+		ExcludeDates: []string{"2025-01-03"},
+	}
+	schedule, err := ParseSchedule(spec)
+	require.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	holidays, err := newTestReconciler().loadHolidays(context.Background(), spec)
+	require.NoError(t, err)
+
+	runs := upcomingRuns(spec, schedule, holidays, time.Date(2025, 1, 2, 12, 0, 0, 0, tokyo))
+	require.Len(t, runs, defaultPreviewCount)
+	for i, day := range []int{4, 5, 6} {
+		assert.Equal(t, time.Date(2025, 1, day, 3, 0, 0, 0, tokyo), runs[i].Time.In(tokyo))
+	}
+
+	spec.ExcludeDates = []string{"2025-13-01"}
+	_, err = ParseSchedule(spec)
+	assert.Error(t, err)
+}
+
+func TestRunSkippedOnHoliday(t *testing.T) {
+	today := time.Now().Format(dateLayout)
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:     "0 3 * * *",
+			TargetRef:    &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+			CalendarRefs: []v1alpha1.CalendarReference{{Name: "operations"}},
+		},
+	}
+	calendar := &v1alpha1.RestartCalendar{
+		ObjectMeta: metav1.ObjectMeta{Name: "operations"},
+		Spec: v1alpha1.RestartCalendarSpec{
+			ConfigMapRef: &v1alpha1.ConfigMapKeyReference{Name: "operations-calendar", Namespace: "ops"},
+		},
+	}
+	reconciler := newTestReconciler(schedule, calendar)
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "api", Namespace: "default"}
+	cronSchedule, err := cron.ParseStandard("0 3 * * *")
+	require.NoError(t, err)
+	lastExecution := func() *v1alpha1.RestartExecution {
+		var updated v1alpha1.RestartSchedule
+		require.NoError(t, reconciler.Get(ctx, key, &updated))
+		require.NotNil(t, updated.Status.LastExecution)
+		return updated.Status.LastExecution
+	}
+
+	// The ConfigMap of the calendar does not exist yet.
+	reconciler.runScheduledRestart(ctx, key, cronSchedule)
+	assert.Equal(t, v1alpha1.ExecutionFailed, lastExecution().Result)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "operations-calendar", Namespace: "ops"},
+		Data: map[string]string{
+			defaultCalendarKey: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:" +
+				time.Now().Format("20060102") + "\nSUMMARY:Thin coverage\nEND:VEVENT\nEND:VCALENDAR\n",
+		},
+	}
+	require.NoError(t, reconciler.Create(ctx, configMap))
+
+	reconciler.runScheduledRestart(ctx, key, cronSchedule)
+	execution := lastExecution()
+	assert.Equal(t, v1alpha1.ExecutionSkipped, execution.Result)
+	assert.Equal(t, `Restart skipped on holiday "Thin coverage"`, execution.Message)
+
+	// Excluded dates of the schedule itself are reported by date.
+	reason, err := reconciler.checkHolidays(ctx, &v1alpha1.RestartScheduleSpec{ExcludeDates: []string{today}})
+	assert.Equal(t, "Holiday", reason)
+	assert.EqualError(t, err, `Restart skipped on holiday "`+today+`"`)
+
+	requests := reconciler.schedulesForCalendar(func() client.ObjectList { return &v1alpha1.RestartScheduleList{} })(ctx, calendar)
+	assert.Equal(t, []ctrl.Request{{NamespacedName: key}}, requests)
+
+	// Changes to the ConfigMap of the calendar enqueue the schedule as well.
+	newList := func() client.ObjectList { return &v1alpha1.RestartScheduleList{} }
+	requests = reconciler.schedulesForConfigMap(newList)(ctx, configMap)
+	assert.Equal(t, []ctrl.Request{{NamespacedName: key}}, requests)
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "operations-calendar", Namespace: "default"}}
+	assert.Empty(t, reconciler.schedulesForConfigMap(newList)(ctx, other))
+	assert.True(t, reconciler.referencedByCalendar(configMap))
+	assert.False(t, reconciler.referencedByCalendar(other), "events of other ConfigMaps are filtered out")
+}
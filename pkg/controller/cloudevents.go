@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	cloudEventTimeout     = 10 * time.Second
+	cloudEventContentType = "application/cloudevents+json"
+
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+// Types of the CloudEvents published for the restart lifecycle.
+const (
+	RestartScheduledEvent       = "k8s.restart-operator.restart.scheduled"
+	RestartStartedEvent         = "k8s.restart-operator.restart.started"
+	RestartRolloutCompleteEvent = "k8s.restart-operator.restart.rollout-complete"
+	RestartSucceededEvent       = "k8s.restart-operator.restart.succeeded"
+	RestartFailedEvent          = "k8s.restart-operator.restart.failed"
+	RestartSkippedEvent         = "k8s.restart-operator.restart.skipped"
+)
+
+// CloudEventSink publishes restart lifecycle events as CloudEvents in
+// structured JSON mode to an HTTP endpoint.
+type CloudEventSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewCloudEventSink(url string) *CloudEventSink {
+	return &CloudEventSink{
+		URL:    url,
+		Client: &http.Client{Timeout: cloudEventTimeout},
+	}
+}
+
+// cloudEvent is a CloudEvent in the JSON event format of the 1.0 spec.
+type cloudEvent struct {
+	SpecVersion     string           `json:"specversion"`
+	ID              string           `json:"id"`
+	Source          string           `json:"source"`
+	Type            string           `json:"type"`
+	Subject         string           `json:"subject,omitempty"`
+	Time            time.Time        `json:"time"`
+	DataContentType string           `json:"datacontenttype"`
+	Data            restartEventData `json:"data"`
+}
+
+type restartEventData struct {
+	Schedule       scheduleReference        `json:"schedule"`
+	Target         *v1alpha1.TargetRef      `json:"target,omitempty"`
+	Revision       string                   `json:"revision,omitempty"`
+	ScheduledTime  *time.Time               `json:"scheduledTime,omitempty"`
+	StartTime      *time.Time               `json:"startTime,omitempty"`
+	CompletionTime *time.Time               `json:"completionTime,omitempty"`
+	Result         v1alpha1.ExecutionResult `json:"result,omitempty"`
+	Message        string                   `json:"message,omitempty"`
+	DryRun         bool                     `json:"dryRun,omitempty"`
+}
+
+// Send POSTs the event to the sink.
+func (s *CloudEventSink) Send(ctx context.Context, event cloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", cloudEventContentType)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("CloudEvents sink responded with %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// publish sends a lifecycle event of the schedule to the CloudEvents sink, if
+// one is configured. Failures are only logged.
+func (r *RestartScheduleReconciler) publish(ctx context.Context, schedule scheduleObject, eventType string, data restartEventData) {
+	if r.CloudEvents == nil {
+		return
+	}
+
+	data.Schedule = referenceTo(schedule)
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              string(uuid.NewUUID()),
+		Source:          scheduleSource(schedule),
+		Type:            eventType,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	if data.Target != nil {
+		event.Subject = fmt.Sprintf("%s/%s", data.Target.Kind, data.Target.Name)
+	}
+
+	if err := r.CloudEvents.Send(ctx, event); err != nil {
+		r.Log.Error(err, "Failed to publish CloudEvent",
+			"restartschedule", client.ObjectKeyFromObject(schedule),
+			"type", eventType)
+	}
+}
+
+// scheduleSource is the API path of the schedule, used as the source of its
+// events.
+func scheduleSource(schedule scheduleObject) string {
+	if schedule.GetNamespace() == "" {
+		return fmt.Sprintf("/apis/%s/clusterrestartschedules/%s",
+			v1alpha1.GroupVersion.String(), schedule.GetName())
+	}
+	return fmt.Sprintf("/apis/%s/namespaces/%s/restartschedules/%s",
+		v1alpha1.GroupVersion.String(), schedule.GetNamespace(), schedule.GetName())
+}
+
+// publishExecution publishes an event carrying the timing and outcome of the
+// execution.
+func (r *RestartScheduleReconciler) publishExecution(ctx context.Context, schedule scheduleObject, eventType string, execution *v1alpha1.RestartExecution) {
+	data := restartEventData{
+		StartTime: &execution.StartTime.Time,
+		Result:    execution.Result,
+		Message:   execution.Message,
+		DryRun:    execution.DryRun,
+	}
+	if execution.CompletionTime != nil {
+		data.CompletionTime = &execution.CompletionTime.Time
+	}
+	r.publish(ctx, schedule, eventType, data)
+}
+
+// publishRolloutComplete publishes one event per target of a stage whose
+// rollout has completed, along with the revision it rolled out to.
+func (r *RestartScheduleReconciler) publishRolloutComplete(ctx context.Context, schedule scheduleObject, targets []v1alpha1.TargetRef) {
+	if r.CloudEvents == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, ref := range targets {
+		revision, err := r.targetRevision(ctx, ref, targetNamespace(ref, schedule.GetNamespace()))
+		if err != nil {
+			r.Log.Error(err, "Failed to read revision of target", "targetKind", ref.Kind, "targetName", ref.Name)
+		}
+		r.publish(ctx, schedule, RestartRolloutCompleteEvent, restartEventData{
+			Target:         &ref,
+			Revision:       revision,
+			CompletionTime: &now,
+		})
+	}
+}
+
+// targetRevision returns the revision a target currently runs: the revision
+// annotation of a Deployment or the update revision reported in status by
+// StatefulSets and similar workloads.
+func (r *RestartScheduleReconciler) targetRevision(ctx context.Context, ref v1alpha1.TargetRef, namespace string) (string, error) {
+	target, err := r.getTarget(ctx, ref, namespace)
+	if err != nil {
+		return "", err
+	}
+	return revisionOf(target)
+}
+
+func revisionOf(target *unstructured.Unstructured) (string, error) {
+	if revision, ok := target.GetAnnotations()[deploymentRevisionAnnotation]; ok {
+		return revision, nil
+	}
+	revision, _, err := unstructured.NestedString(target.Object, "status", "updateRevision")
+	return revision, err
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// eventSink records the CloudEvents posted to it.
+type eventSink struct {
+	mu           sync.Mutex
+	events       []cloudEvent
+	contentTypes []string
+}
+
+func (s *eventSink) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var event cloudEvent
+	_ = json.NewDecoder(req.Body).Decode(&event)
+
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	s.contentTypes = append(s.contentTypes, req.Header.Get("Content-Type"))
+	s.mu.Unlock()
+
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func (s *eventSink) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, event := range s.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestCloudEventsForRestartLifecycle(t *testing.T) {
+	pollFast(t)
+
+	sink := &eventSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			Stages: []v1alpha1.RestartStage{
+				{Name: "backend", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "api"}}},
+			},
+		},
+	}
+	api := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			Annotations: map[string]string{deploymentRevisionAnnotation: "7"},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	reconciler := newTestReconciler(schedule, api)
+	reconciler.CloudEvents = NewCloudEventSink(server.URL)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	assert.Equal(t, []string{
+		RestartStartedEvent,
+		RestartRolloutCompleteEvent,
+		RestartSucceededEvent,
+		RestartScheduledEvent,
+	}, sink.types())
+
+	for i, event := range sink.events {
+		assert.Equal(t, "1.0", event.SpecVersion)
+		assert.NotEmpty(t, event.ID)
+		assert.Equal(t, "/apis/restart-operator.k8s/v1alpha1/namespaces/default/restartschedules/test-schedule", event.Source)
+		assert.Equal(t, "test-schedule", event.Data.Schedule.Name)
+		assert.Equal(t, cloudEventContentType, sink.contentTypes[i])
+	}
+
+	rollout := sink.events[1]
+	assert.Equal(t, "Deployment/api", rollout.Subject)
+	require.NotNil(t, rollout.Data.Target)
+	assert.Equal(t, "api", rollout.Data.Target.Name)
+	assert.Equal(t, "7", rollout.Data.Revision)
+
+	succeeded := sink.events[2]
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, succeeded.Data.Result)
+	assert.NotNil(t, succeeded.Data.StartTime)
+	assert.NotNil(t, succeeded.Data.CompletionTime)
+
+	scheduled := sink.events[3]
+	require.NotNil(t, scheduled.Data.ScheduledTime)
+	assert.Equal(t, cronSchedule.Next(time.Now()).Unix(), scheduled.Data.ScheduledTime.Unix())
+}
+
+func TestReconcilePublishesScheduledEventOnce(t *testing.T) {
+	sink := &eventSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	base := newTestReconciler(schedule)
+	reconciler := NewRestartScheduleReconciler(base.Client, base.Scheme, base.Recorder, nil)
+	defer reconciler.cron.Stop()
+	reconciler.CloudEvents = NewCloudEventSink(server.URL)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-schedule", Namespace: "default"}}
+	for range 2 {
+		_, err := reconciler.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool { return len(sink.types()) > 0 }, time.Second, 10*time.Millisecond)
+	assert.Never(t, func() bool { return len(sink.types()) > 1 }, 200*time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, []string{RestartScheduledEvent}, sink.types())
+}
+
+func TestReconcileDoesNotPublishWhenStatusPatchFails(t *testing.T) {
+	sink := &eventSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	reconciler := newInterceptedTestReconciler(interceptor.Funcs{
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			return errors.New("conflict")
+		},
+	}, newTestSchedule())
+	reconciler.CloudEvents = NewCloudEventSink(server.URL)
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-schedule", Namespace: "default"}})
+	require.Error(t, err)
+
+	assert.Never(t, func() bool { return len(sink.types()) > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestReconcilePublishesWithoutHoldingScheduleLock(t *testing.T) {
+	schedule := newTestSchedule()
+	reconciler := newTestReconciler(schedule)
+
+	// The sink unregisters another schedule, which needs the lock the
+	// reconcile would otherwise still hold while publishing.
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		reconciler.unschedule("default/other-schedule")
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	reconciler.CloudEvents = NewCloudEventSink(server.URL)
+
+	done := make(chan error)
+	go func() {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-schedule", Namespace: "default"}})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconcile did not finish while the sink was waiting for the lock")
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// ClusterRestartScheduleReconciler reconciles ClusterRestartSchedules. It
+// shares the cron scheduler and restart engine of the RestartSchedule
+// reconciler; cron entries are keyed by namespaced name and the namespace
+// of a cluster-scoped schedule is empty, so they never collide with namespaced ones.
+type ClusterRestartScheduleReconciler struct {
+	*RestartScheduleReconciler
+}
+
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=clusterrestartschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=clusterrestartschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=clusterrestartschedules/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+func (r *ClusterRestartScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcileSchedule(ctx, req, func() scheduleObject { return &v1alpha1.ClusterRestartSchedule{} })
+}
+
+func (r *ClusterRestartScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.ClusterRestartSchedule{}, targetIndexKey, indexTargets); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.ClusterRestartSchedule{}, policyIndexKey, indexPolicyRef); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.ClusterRestartSchedule{}, calendarIndexKey, indexCalendarRefs); err != nil {
+		return err
+	}
+
+	newList := func() client.ObjectList { return &v1alpha1.ClusterRestartScheduleList{} }
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterRestartSchedule{}).
+		Watches(&v1alpha1.ClusterRestartPolicy{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForPolicy(newList))).
+		Watches(&v1alpha1.RestartCalendar{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForCalendar(newList)))
+	bldr = r.watchCalendarConfigMaps(bldr, newList)
+	return r.watchTargets(bldr, newList).
+		Complete(r)
+}
+
+// resolveTargets replaces the target selector of a ClusterRestartSchedule
+// with the workloads it currently matches, sorted by namespace and name. It
+// only changes the in-memory copy of the schedule.
+func (r *RestartScheduleReconciler) resolveTargets(ctx context.Context, schedule scheduleObject) error {
+	cluster, ok := schedule.(*v1alpha1.ClusterRestartSchedule)
+	if !ok || cluster.Spec.TargetSelector == nil {
+		return nil
+	}
+	selector := cluster.Spec.TargetSelector
+
+	ref := v1alpha1.TargetRef{APIVersion: selector.APIVersion, Kind: selector.Kind}
+	gvk, _, err := r.targetKinds().Lookup(ref)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := r.selectedNamespaces(ctx, selector.NamespaceSelector)
+	if err != nil {
+		return err
+	}
+
+	var listOptions []client.ListOption
+	if selector.Selector != nil {
+		labelSelector, err := metav1.LabelSelectorAsSelector(selector.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid target selector: %w", err)
+		}
+		listOptions = append(listOptions, client.MatchingLabelsSelector{Selector: labelSelector})
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := r.List(ctx, list, listOptions...); err != nil {
+		return fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+	}
+
+	var targets []v1alpha1.TargetRef
+	for _, item := range list.Items {
+		if namespaces != nil && !namespaces.Has(item.GetNamespace()) {
+			continue
+		}
+		targets = append(targets, v1alpha1.TargetRef{
+			APIVersion: selector.APIVersion,
+			Kind:       selector.Kind,
+			Name:       item.GetName(),
+			Namespace:  item.GetNamespace(),
+		})
+	}
+	slices.SortFunc(targets, func(a, b v1alpha1.TargetRef) int {
+		if c := strings.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if len(targets) == 0 {
+		return &skipError{
+			reason:  "NoTargets",
+			message: fmt.Sprintf("No %s matches the target selector", selector.Kind),
+		}
+	}
+	cluster.Spec.Targets = targets
+	return nil
+}
+
+// selectsWorkload reports whether the target selector of the cluster schedule
+// matches the labels and namespace of the workload.
+func (r *RestartScheduleReconciler) selectsWorkload(ctx context.Context, cluster *v1alpha1.ClusterRestartSchedule, obj client.Object) (bool, error) {
+	selector := cluster.Spec.TargetSelector
+	if selector == nil {
+		return false, nil
+	}
+	if selector.Selector != nil {
+		labelSelector, err := metav1.LabelSelectorAsSelector(selector.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid target selector: %w", err)
+		}
+		if !labelSelector.Matches(labels.Set(obj.GetLabels())) {
+			return false, nil
+		}
+	}
+	if selector.NamespaceSelector == nil {
+		return true, nil
+	}
+
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(selector.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, client.ObjectKey{Name: obj.GetNamespace()}, &namespace); err != nil {
+		return false, fmt.Errorf("failed to get namespace %s: %w", obj.GetNamespace(), err)
+	}
+	return namespaceSelector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// selectedNamespaces returns the names of the namespaces matching the
+// selector, or nil if every namespace is selected.
+func (r *RestartScheduleReconciler) selectedNamespaces(ctx context.Context, selector *metav1.LabelSelector) (sets.Set[string], error) {
+	if selector == nil {
+		return nil, nil
+	}
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+
+	var namespaceList corev1.NamespaceList
+	if err := r.List(ctx, &namespaceList, client.MatchingLabelsSelector{Selector: namespaceSelector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	names := sets.New[string]()
+	for _, namespace := range namespaceList.Items {
+		if namespaceSelector.Matches(labels.Set(namespace.Labels)) {
+			names.Insert(namespace.Name)
+		}
+	}
+	return names, nil
+}
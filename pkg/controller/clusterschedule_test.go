@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func ingressController(name, namespace string, labels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+}
+
+func clusterTestSchedule(selector *v1alpha1.ClusterTargetSelector) *v1alpha1.ClusterRestartSchedule {
+	return &v1alpha1.ClusterRestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-controllers"},
+		Spec: v1alpha1.ClusterRestartScheduleSpec{
+			RestartScheduleSpec: v1alpha1.RestartScheduleSpec{Schedule: "0 3 * * 0"},
+			TargetSelector:      selector,
+		},
+	}
+}
+
+func restartedDeployments(t *testing.T, c client.Client) []string {
+	t.Helper()
+	var deployments appsv1.DeploymentList
+	require.NoError(t, c.List(context.Background(), &deployments))
+
+	var restarted []string
+	for _, deployment := range deployments.Items {
+		if _, ok := deployment.Spec.Template.Annotations[restartedAtAnnotation]; ok {
+			restarted = append(restarted, deployment.Namespace+"/"+deployment.Name)
+		}
+	}
+	return restarted
+}
+
+func TestClusterScheduleRestartsSelectedWorkloads(t *testing.T) {
+	ingress := map[string]string{"app": "ingress-nginx"}
+	schedule := clusterTestSchedule(&v1alpha1.ClusterTargetSelector{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"tier": "platform"},
+		},
+		Selector: &metav1.LabelSelector{MatchLabels: ingress},
+	})
+	reconciler := &ClusterRestartScheduleReconciler{RestartScheduleReconciler: newTestReconciler(
+		schedule,
+		namespace("ingress-b", map[string]string{"tier": "platform"}),
+		namespace("ingress-a", map[string]string{"tier": "platform"}),
+		namespace("team-a", nil),
+		ingressController("controller", "ingress-b", ingress),
+		ingressController("controller", "ingress-a", ingress),
+		ingressController("controller", "team-a", ingress),
+		ingressController("default-backend", "ingress-a", nil),
+	)}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "ingress-controllers"}
+	reconciler.run(context.Background(), &v1alpha1.ClusterRestartSchedule{}, key, cronSchedule, v1alpha1.ScheduledRestart)
+
+	var updated v1alpha1.ClusterRestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+
+	assert.ElementsMatch(t, []string{"ingress-a/controller", "ingress-b/controller"},
+		restartedDeployments(t, reconciler.Client))
+	assert.Nil(t, updated.Spec.Targets, "resolved targets must not be written back")
+}
+
+func TestClusterScheduleEnqueuedOnlyForSelectedWorkloads(t *testing.T) {
+	ingress := map[string]string{"app": "ingress-nginx"}
+	schedule := clusterTestSchedule(&v1alpha1.ClusterTargetSelector{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"tier": "platform"},
+		},
+		Selector: &metav1.LabelSelector{MatchLabels: ingress},
+	})
+	reconciler := &ClusterRestartScheduleReconciler{RestartScheduleReconciler: newTestReconciler(
+		schedule,
+		namespace("ingress-a", map[string]string{"tier": "platform"}),
+		namespace("team-a", nil),
+	)}
+	mapTarget := reconciler.schedulesForTarget("Deployment",
+		func() client.ObjectList { return &v1alpha1.ClusterRestartScheduleList{} })
+
+	expected := []ctrl.Request{{NamespacedName: types.NamespacedName{Name: "ingress-controllers"}}}
+	assert.Equal(t, expected, mapTarget(context.Background(), ingressController("controller", "ingress-a", ingress)))
+	assert.Empty(t, mapTarget(context.Background(), ingressController("controller", "team-a", ingress)),
+		"workloads in namespaces that are not selected must not enqueue the schedule")
+	assert.Empty(t, mapTarget(context.Background(), ingressController("default-backend", "ingress-a", nil)),
+		"workloads without the selected labels must not enqueue the schedule")
+}
+
+func TestClusterScheduleWithoutMatchesIsSkipped(t *testing.T) {
+	schedule := clusterTestSchedule(&v1alpha1.ClusterTargetSelector{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "ingress-nginx"},
+		},
+	})
+	reconciler := &ClusterRestartScheduleReconciler{RestartScheduleReconciler: newTestReconciler(schedule, ingressController("api", "default", nil))}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "ingress-controllers"}
+	reconciler.run(context.Background(), &v1alpha1.ClusterRestartSchedule{}, key, cronSchedule, v1alpha1.ScheduledRestart)
+
+	var updated v1alpha1.ClusterRestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, updated.Status.LastExecution.Result)
+	assert.Contains(t, updated.Status.LastExecution.Message, "No Deployment matches")
+	assert.Empty(t, restartedDeployments(t, reconciler.Client))
+}
+
+func TestReconcileClusterSchedule(t *testing.T) {
+	schedule := clusterTestSchedule(nil)
+	schedule.Spec.Targets = []v1alpha1.TargetRef{
+		{Kind: "Deployment", Name: "controller", Namespace: "ingress-nginx"},
+	}
+	reconciler := &ClusterRestartScheduleReconciler{RestartScheduleReconciler: newTestReconciler(schedule)}
+
+	key := types.NamespacedName{Name: "ingress-controllers"}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	assert.Contains(t, reconciler.scheduleIDs, key.String())
+
+	var updated v1alpha1.ClusterRestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.NotNil(t, updated.Status.NextScheduledTime)
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "Valid"))
+}
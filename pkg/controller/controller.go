@@ -2,21 +2,28 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/robfig/cron/v3"
-	appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -26,15 +33,39 @@ type RestartScheduleReconciler struct {
 	Recorder record.EventRecorder
 	Log      logr.Logger
 
+	TargetKinds *TargetKindRegistry
+	CloudEvents *CloudEventSink
+	Annotations AnnotationKeys
+
+	// AllowCrossNamespaceTargets lets RestartSchedules restart workloads in
+	// other namespaces without a RestartTargetGrant.
+	AllowCrossNamespaceTargets bool
+
+	// DryRun makes every run a dry run, as if all schedules set dryRun.
+	DryRun bool
+
+	// TracerProvider creates the spans of reconciles and restart executions.
+	// The global provider is used when it is nil.
+	TracerProvider trace.TracerProvider
+
 	cron        *cron.Cron
 	scheduleIDs map[string]cron.EntryID
-	mu          sync.RWMutex
+	// runContexts holds, per schedule, the context its runs are canceled
+	// through when it is unscheduled.
+	runContexts map[string]cancelableContext
+	// running holds the schedules with a run in progress, so that a run
+	// never overlaps with another one of the same schedule.
+	running sets.Set[string]
+	// mu guards scheduleIDs, runContexts and running. It is never held across calls
+	// to the API server or other services.
+	mu sync.RWMutex
 }
 
 func NewRestartScheduleReconciler(
 	client client.Client,
 	scheme *runtime.Scheme,
 	recorder record.EventRecorder,
+	targetKinds *TargetKindRegistry,
 ) *RestartScheduleReconciler {
 	cronScheduler := cron.New()
 	cronScheduler.Start()
@@ -43,6 +74,7 @@ func NewRestartScheduleReconciler(
 		Client:      client,
 		Scheme:      scheme,
 		Recorder:    recorder,
+		TargetKinds: targetKinds,
 		Log:         log.Log.WithName("controller").WithName("RestartSchedule"),
 		cron:        cronScheduler,
 		scheduleIDs: make(map[string]cron.EntryID),
@@ -53,34 +85,104 @@ func NewRestartScheduleReconciler(
 // +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartschedules,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartschedules/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartschedules/finalizers,verbs=update
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartnotifiers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=restarttargetgrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartpolicies;clusterrestartpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=restart-operator.k8s,resources=restartcalendars,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get
 func (r *RestartScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := log.FromContext(ctx).WithValues("restartschedule", req.NamespacedName)
-	logger.Info("Reconciling RestartSchedule")
-
-	var restartSchedule v1alpha1.RestartSchedule
-	if err := r.Get(ctx, req.NamespacedName, &restartSchedule); err != nil {
-		if errors.IsNotFound(err) {
-			r.mu.Lock()
-			defer r.mu.Unlock()
-
-			if id, exists := r.scheduleIDs[req.String()]; exists {
-				r.cron.Remove(id)
-				delete(r.scheduleIDs, req.String())
-				logger.Info("Removed schedule for deleted RestartSchedule")
+	return r.reconcileSchedule(ctx, req, func() scheduleObject { return &v1alpha1.RestartSchedule{} })
+}
+
+// reconcileSchedule registers the cron entry of a RestartSchedule or
+// ClusterRestartSchedule and reports the next run in its status. newSchedule
+// returns an empty object of the reconciled kind.
+func (r *RestartScheduleReconciler) reconcileSchedule(
+	ctx context.Context,
+	req ctrl.Request,
+	newSchedule func() scheduleObject,
+) (result ctrl.Result, err error) {
+	ctx, span := r.startSpan(ctx, "Reconcile", req.NamespacedName)
+	defer func() { endSpan(span, err) }()
+
+	schedule := newSchedule()
+	kind := scheduleKind(schedule)
+	logger := log.FromContext(ctx).WithValues(strings.ToLower(kind), req.NamespacedName)
+	logger.Info("Reconciling " + kind)
+
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if apierrors.IsNotFound(err) {
+			if r.unschedule(req.String()) {
+				logger.Info("Removed schedule for deleted " + kind)
 			}
 			return ctrl.Result{}, nil
 		}
-		logger.Error(err, "Failed to get RestartSchedule")
+		logger.Error(err, "Failed to get "+kind)
+		return ctrl.Result{}, err
+	}
+
+	if !schedule.GetDeletionTimestamp().IsZero() {
+		if err := r.finalize(ctx, schedule, req.String()); err != nil {
+			logger.Error(err, "Failed to finalize "+kind)
+			return ctrl.Result{}, err
+		}
+		logger.Info("Finalized deleted " + kind)
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.ensureMetadata(ctx, schedule); err != nil {
+		logger.Error(err, "Failed to update "+kind+" finalizers and owner references")
 		return ctrl.Result{}, err
 	}
 
-	cronSchedule, err := cron.ParseStandard(restartSchedule.Spec.Schedule)
+	policyErr := r.applyPolicy(ctx, schedule)
+	statusBase := copySchedule(schedule)
+	spec := schedule.GetScheduleSpec()
+	schedule.GetScheduleStatus().ObservedGeneration = schedule.GetGeneration()
+
+	if policyErr != nil {
+		reason, message := "PolicyNotFound", fmt.Sprintf("%s %s not found", policyKind(spec.PolicyRef), spec.PolicyRef.Name)
+		var invalid *invalidPolicyError
+		if errors.As(policyErr, &invalid) {
+			reason, message = "InvalidPolicy", invalid.Error()
+		} else if !apierrors.IsNotFound(policyErr) {
+			logger.Error(policyErr, "Failed to get policy")
+			return ctrl.Result{}, policyErr
+		}
+		if r.unschedule(req.String()) {
+			logger.Info("Removed schedule of "+kind+" without usable policy", "reason", reason)
+		}
+
+		applyCondition(schedule, metav1.Condition{
+			Type:               "Valid",
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: metav1.Now(),
+		})
+		schedule.GetScheduleStatus().NextScheduledTime = nil
+		schedule.GetScheduleStatus().UpcomingRuns = nil
+		if err := r.patchStatus(ctx, schedule, statusBase); err != nil {
+			logger.Error(err, "Failed to update "+kind+" status with error condition")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(schedule, "Warning", reason, message)
+		return ctrl.Result{}, nil
+	}
+
+	cronSchedule, err := ParseSchedule(spec)
 	if err != nil {
-		logger.Error(err, "Invalid cron schedule", "schedule", restartSchedule.Spec.Schedule)
+		logger.Error(err, "Invalid cron schedule", "schedule", spec.Schedule)
+		if r.unschedule(req.String()) {
+			logger.Info("Removed schedule of " + kind + " with invalid schedule")
+		}
 
 		condition := metav1.Condition{
 			Type:               "Valid",
@@ -89,68 +191,47 @@ func (r *RestartScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 			Message:            fmt.Sprintf("Invalid schedule: %v", err),
 			LastTransitionTime: metav1.Now(),
 		}
-		applyCondition(&restartSchedule, condition)
+		applyCondition(schedule, condition)
+		schedule.GetScheduleStatus().NextScheduledTime = nil
+		schedule.GetScheduleStatus().UpcomingRuns = nil
 
-		if updateErr := r.Status().Update(ctx, &restartSchedule); updateErr != nil {
-			logger.Error(updateErr, "Failed to update RestartSchedule status with error condition")
+		if updateErr := r.patchStatus(ctx, schedule, statusBase); updateErr != nil {
+			logger.Error(updateErr, "Failed to update "+kind+" status with error condition")
 		}
 
-		r.Recorder.Event(&restartSchedule, "Warning", "InvalidSchedule", fmt.Sprintf("Invalid schedule: %v", err))
+		r.Recorder.Event(schedule, "Warning", "InvalidSchedule", fmt.Sprintf("Invalid schedule: %v", err))
 
 		return ctrl.Result{}, err
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if id, exists := r.scheduleIDs[req.String()]; exists {
-		r.cron.Remove(id)
-		delete(r.scheduleIDs, req.String())
-		logger.Info("Removed existing schedule", "id", id)
-	}
-
-	logger.Info("Adding new schedule", "schedule", restartSchedule.Spec.Schedule)
-
-	id, err := r.cron.AddFunc(restartSchedule.Spec.Schedule, func() {
-		jobCtx := context.Background()
-		jobLogger := r.Log.WithValues(
-			"restartschedule", req.NamespacedName,
-			"execution", time.Now().Format(time.RFC3339),
-		)
-
-		var latestSchedule v1alpha1.RestartSchedule
-		err := r.Get(jobCtx, req.NamespacedName, &latestSchedule)
-		if err != nil {
-			jobLogger.Error(err, "Failed to get latest RestartSchedule for scheduled restart")
-			return
+	if err := r.authorizeTargets(ctx, schedule); err != nil {
+		var forbidden *forbiddenError
+		if !errors.As(err, &forbidden) {
+			logger.Error(err, "Failed to authorize targets")
+			return ctrl.Result{}, err
 		}
-
-		if err := r.restartResource(jobCtx, &latestSchedule); err != nil {
-			jobLogger.Error(err, "Failed to restart resource")
-			return
+		logger.Info("Schedule targets a workload it may not restart", "reason", err.Error())
+		if r.unschedule(req.String()) {
+			logger.Info("Removed schedule of " + kind + " with forbidden targets")
 		}
 
-		now := metav1.Now()
-		latestSchedule.Status.LastSuccessfulTime = &now
-
-		next := cronSchedule.Next(time.Now())
-		latestSchedule.Status.NextScheduledTime = &metav1.Time{Time: next}
-
-		if err := r.Status().Update(jobCtx, &latestSchedule); err != nil {
-			jobLogger.Error(err, "Failed to update status after restart")
+		applyCondition(schedule, metav1.Condition{
+			Type:               "Valid",
+			Status:             metav1.ConditionFalse,
+			Reason:             "Forbidden",
+			Message:            err.Error(),
+			LastTransitionTime: metav1.Now(),
+		})
+		schedule.GetScheduleStatus().NextScheduledTime = nil
+		schedule.GetScheduleStatus().UpcomingRuns = nil
+		if updateErr := r.patchStatus(ctx, schedule, statusBase); updateErr != nil {
+			logger.Error(updateErr, "Failed to update "+kind+" status with error condition")
+			return ctrl.Result{}, updateErr
 		}
-	})
-
-	if err != nil {
-		logger.Error(err, "Failed to add cron job")
-		return ctrl.Result{}, err
+		r.Recorder.Event(schedule, "Warning", "Forbidden", err.Error())
+		return ctrl.Result{}, nil
 	}
 
-	r.scheduleIDs[req.String()] = id
-
-	next := cronSchedule.Next(time.Now())
-	restartSchedule.Status.NextScheduledTime = &metav1.Time{Time: next}
-
 	condition := metav1.Condition{
 		Type:               "Valid",
 		Status:             metav1.ConditionTrue,
@@ -158,143 +239,388 @@ func (r *RestartScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		Message:            "Schedule is valid and has been registered",
 		LastTransitionTime: metav1.Now(),
 	}
-	applyCondition(&restartSchedule, condition)
 
-	if err := r.Status().Update(ctx, &restartSchedule); err != nil {
-		logger.Error(err, "Failed to update RestartSchedule status")
+	var scheduled *time.Time
+	if spec.Suspend {
+		logger.Info("Schedule is suspended")
+		r.register(req.String(), nil, nil)
+		schedule.GetScheduleStatus().NextScheduledTime = nil
+		schedule.GetScheduleStatus().UpcomingRuns = nil
+		condition.Reason = "Suspended"
+		condition.Message = "Schedule is valid and suspended"
+	} else {
+		logger.Info("Adding new schedule", "schedule", spec.Schedule)
+
+		jitter := spec.Jitter
+		r.register(req.String(), cronSchedule, cron.FuncJob(func() {
+			time.Sleep(jitterDelay(jitter))
+			r.run(context.Background(), newSchedule(), req.NamespacedName, cronSchedule, v1alpha1.ScheduledRestart)
+		}))
+
+		// A calendar that cannot be read fails the runs, which report the
+		// error; the preview just leaves the calendar out meanwhile.
+		holidays, err := r.loadHolidays(ctx, spec)
+		if err != nil {
+			logger.Error(err, "Failed to load calendars")
+		}
+		now := time.Now()
+		schedule.GetScheduleStatus().UpcomingRuns = upcomingRuns(spec, cronSchedule, holidays, now)
+
+		// The next run is the first one that will not be skipped.
+		schedule.GetScheduleStatus().NextScheduledTime = nil
+		if next := nextRun(spec, cronSchedule, holidays, now); next != nil {
+			if previous := statusBase.GetScheduleStatus().NextScheduledTime; previous == nil || !previous.Time.Equal(*next) {
+				scheduled = next
+			}
+			schedule.GetScheduleStatus().NextScheduledTime = &metav1.Time{Time: *next}
+		}
+	}
+	applyCondition(schedule, condition)
+
+	if err := r.refreshTargetStatus(ctx, schedule); err != nil {
+		logger.Error(err, "Failed to look up targets")
 		return ctrl.Result{}, err
 	}
 
-	logger.Info("Successfully reconciled RestartSchedule",
-		"nextRun", next.Format(time.RFC3339))
+	trigger, triggered := pendingTrigger(schedule)
+	if triggered {
+		schedule.GetScheduleStatus().LastHandledTrigger = trigger
+	}
+
+	if err := r.patchStatus(ctx, schedule, statusBase); err != nil {
+		logger.Error(err, "Failed to update "+kind+" status")
+		return ctrl.Result{}, err
+	}
+
+	// The event is only published once the new next run is recorded, so a
+	// failing status update does not announce it again on every retry. The
+	// sink may be slow, so the reconcile does not wait for it.
+	if scheduled != nil {
+		go r.publish(context.WithoutCancel(ctx), schedule, RestartScheduledEvent, restartEventData{ScheduledTime: scheduled})
+	}
+
+	// The trigger is recorded before the run starts, so a failing status
+	// update cannot start the same manual run twice.
+	if triggered {
+		logger.Info("Starting manual run", "trigger", trigger)
+		r.Recorder.Event(schedule, "Normal", "ManualRestart", fmt.Sprintf("Manual restart requested at %s", trigger))
+		go r.run(context.Background(), newSchedule(), req.NamespacedName, cronSchedule, v1alpha1.ManualRestart)
+	}
+
+	logger.Info("Successfully reconciled "+kind, "suspended", spec.Suspend)
 
 	return ctrl.Result{}, nil
 }
 
-func (r *RestartScheduleReconciler) restartResource(ctx context.Context, schedule *v1alpha1.RestartSchedule) error {
-	logger := r.Log.WithValues(
-		"restartschedule", types.NamespacedName{Name: schedule.Name, Namespace: schedule.Namespace},
-		"targetKind", schedule.Spec.TargetRef.Kind,
-		"targetName", schedule.Spec.TargetRef.Name,
-	)
+// pendingTrigger returns the value of the trigger annotation if a manual run
+// has been requested with it that has not been started yet.
+func pendingTrigger(schedule scheduleObject) (string, bool) {
+	trigger, ok := schedule.GetAnnotations()[v1alpha1.TriggerAnnotation]
+	if !ok || trigger == "" || trigger == schedule.GetScheduleStatus().LastHandledTrigger {
+		return "", false
+	}
+	return trigger, true
+}
 
-	targetNamespace := schedule.Spec.TargetRef.Namespace
-	if targetNamespace == "" {
-		targetNamespace = schedule.Namespace
-		logger.Info("Using RestartSchedule namespace for target", "namespace", targetNamespace)
-	}
-
-	switch schedule.Spec.TargetRef.Kind {
-	case "Deployment":
-		return r.restartDeployment(ctx, schedule.Spec.TargetRef.Name, targetNamespace)
-	case "StatefulSet":
-		return r.restartStatefulSet(ctx, schedule.Spec.TargetRef.Name, targetNamespace)
-	case "DaemonSet":
-		return r.restartDaemonSet(ctx, schedule.Spec.TargetRef.Name, targetNamespace)
-	default:
-		err := fmt.Errorf("unsupported resource kind: %s", schedule.Spec.TargetRef.Kind)
-		logger.Error(err, "Unsupported target kind")
-		r.Recorder.Event(schedule, "Warning", "UnsupportedKind",
-			fmt.Sprintf("Unsupported target kind: %s", schedule.Spec.TargetRef.Kind))
-		return err
+// ParseSchedule parses the cron expression of the spec in its time zone and
+// checks its blackout windows and excluded dates. kubectl-restart uses it as well, so its
+// previews match the runs of the operator.
+func ParseSchedule(spec *v1alpha1.RestartScheduleSpec) (cron.Schedule, error) {
+	schedule, err := ParseCron(spec.Schedule, spec.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	for _, window := range spec.BlackoutWindows {
+		if _, err := ParseCron(window.Schedule, spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid blackout window %q: %w", window.Name, err)
+		}
+		if window.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("invalid blackout window %q: duration must be positive", window.Name)
+		}
+	}
+	if err := parseExcludeDates(spec); err != nil {
+		return nil, err
 	}
+	return schedule, nil
 }
 
-func (r *RestartScheduleReconciler) restartDeployment(ctx context.Context, name, namespace string) error {
-	logger := r.Log.WithValues("kind", "Deployment", "name", name, "namespace", namespace)
-	logger.Info("Restarting Deployment")
+// ParseCron parses a standard cron expression evaluated in the given IANA
+// time zone, or in the local time zone if it is empty.
+func ParseCron(expression, timeZone string) (cron.Schedule, error) {
+	if timeZone == "" {
+		return cron.ParseStandard(expression)
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return cron.ParseStandard("CRON_TZ=" + timeZone + " " + expression)
+}
 
-	var deployment appsv1.Deployment
-	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &deployment); err != nil {
-		logger.Error(err, "Failed to get Deployment")
-		return err
+// activeBlackoutWindow returns the name of the blackout window of the spec
+// covering t, or an empty string if there is none.
+func activeBlackoutWindow(spec *v1alpha1.RestartScheduleSpec, t time.Time) (string, error) {
+	return blackoutWindowDuring(spec, t, t)
+}
+
+// blackoutWindowDuring returns the name of a blackout window of the spec
+// overlapping the interval from from to to, or an empty string if there is
+// none.
+func blackoutWindowDuring(spec *v1alpha1.RestartScheduleSpec, from, to time.Time) (string, error) {
+	for _, window := range spec.BlackoutWindows {
+		start, err := ParseCron(window.Schedule, spec.TimeZone)
+		if err != nil {
+			return "", fmt.Errorf("invalid blackout window %q: %w", window.Name, err)
+		}
+		// The window overlaps the interval if one of its starts lies within
+		// its duration before from and no later than to.
+		if !start.Next(from.Add(-window.Duration.Duration)).After(to) {
+			return window.Name, nil
+		}
 	}
+	return "", nil
+}
 
-	if deployment.Spec.Template.ObjectMeta.Annotations == nil {
-		deployment.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+// defaultPreviewCount is the number of upcoming runs previewed when the
+// schedule does not set previewCount.
+const defaultPreviewCount = 3
+
+// maxPreviewCandidates bounds the fire times upcomingRuns looks at, so a
+// schedule that mostly falls into blackout windows cannot stall a reconcile.
+const maxPreviewCandidates = 1000
+
+// upcomingRuns returns the runs of the spec following t, up to its preview
+// count.
+func upcomingRuns(
+	spec *v1alpha1.RestartScheduleSpec,
+	cronSchedule cron.Schedule,
+	holidays *holidays,
+	t time.Time,
+) []v1alpha1.UpcomingRun {
+	count := int32(defaultPreviewCount)
+	if spec.PreviewCount != nil {
+		count = *spec.PreviewCount
 	}
+	return nextRuns(spec, cronSchedule, holidays, t, count)
+}
 
-	deployment.Spec.Template.ObjectMeta.Annotations["restart-operator.k8s/restartedAt"] = time.Now().Format(time.RFC3339)
+// nextRun returns the time of the first run of the spec following t, or nil
+// if there is none.
+func nextRun(
+	spec *v1alpha1.RestartScheduleSpec,
+	cronSchedule cron.Schedule,
+	holidays *holidays,
+	t time.Time,
+) *time.Time {
+	runs := nextRuns(spec, cronSchedule, holidays, t, 1)
+	if len(runs) == 0 {
+		return nil
+	}
+	return &runs[0].Time.Time
+}
 
-	if err := r.Update(ctx, &deployment); err != nil {
-		logger.Error(err, "Failed to update Deployment")
-		return err
+// nextRuns returns up to count runs of the spec following t. Fire times are
+// left out if a blackout window or one of the holidays falls between them
+// and their latest start after the jitter, as those runs will or may be
+// skipped.
+func nextRuns(
+	spec *v1alpha1.RestartScheduleSpec,
+	cronSchedule cron.Schedule,
+	holidays *holidays,
+	t time.Time,
+	count int32,
+) []v1alpha1.UpcomingRun {
+	location, err := scheduleLocation(spec)
+	if err != nil {
+		location = time.Local
+	}
+	var jitter time.Duration
+	if spec.Jitter != nil && spec.Jitter.Duration > 0 {
+		jitter = spec.Jitter.Duration
 	}
 
-	logger.Info("Successfully restarted Deployment")
-	return nil
+	var runs []v1alpha1.UpcomingRun
+	for i := 0; i < maxPreviewCandidates && int32(len(runs)) < count; i++ {
+		t = cronSchedule.Next(t)
+		if t.IsZero() {
+			break
+		}
+		latest := t.Add(jitter)
+		if window, err := blackoutWindowDuring(spec, t, latest); err != nil || window != "" {
+			continue
+		}
+		if _, ok := holidays.lookup(t, location); ok {
+			continue
+		}
+		if _, ok := holidays.lookup(latest, location); ok {
+			continue
+		}
+		run := v1alpha1.UpcomingRun{Time: metav1.NewTime(t)}
+		if jitter > 0 {
+			run.LatestTime = &metav1.Time{Time: latest}
+		}
+		runs = append(runs, run)
+	}
+	return runs
 }
 
-func (r *RestartScheduleReconciler) restartStatefulSet(ctx context.Context, name, namespace string) error {
-	logger := r.Log.WithValues("kind", "StatefulSet", "name", name, "namespace", namespace)
-	logger.Info("Restarting StatefulSet")
-
-	var statefulSet appsv1.StatefulSet
-	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &statefulSet); err != nil {
-		logger.Error(err, "Failed to get StatefulSet")
-		return err
+// jitterDelay returns a random delay of up to jitter.
+func jitterDelay(jitter *metav1.Duration) time.Duration {
+	if jitter == nil || jitter.Duration <= 0 {
+		return 0
 	}
+	return rand.N(jitter.Duration)
+}
+
+// restartResource restarts a target of the schedule as part of the execution
+// and records an event on the schedule and, if it exists, on the target.
+func (r *RestartScheduleReconciler) restartResource(
+	ctx context.Context,
+	schedule scheduleObject,
+	ref v1alpha1.TargetRef,
+	execution *v1alpha1.RestartExecution,
+) (*unstructured.Unstructured, error) {
+	logger := r.Log.WithValues(
+		"restartschedule", client.ObjectKeyFromObject(schedule),
+		"targetKind", ref.Kind,
+		"targetName", ref.Name,
+	)
 
-	if statefulSet.Spec.Template.ObjectMeta.Annotations == nil {
-		statefulSet.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+	if ref.Namespace == "" {
+		logger.Info("Using "+scheduleKind(schedule)+" namespace for target", "namespace", schedule.GetNamespace())
 	}
 
-	statefulSet.Spec.Template.ObjectMeta.Annotations["restart-operator.k8s/restartedAt"] = time.Now().Format(time.RFC3339)
+	namespace := targetNamespace(ref, schedule.GetNamespace())
+	ctx, span := r.startSpan(ctx, "RestartTarget", client.ObjectKeyFromObject(schedule), targetAttributes(ref, namespace)...)
+	keys := r.Annotations.withDefaults()
+	target, err := r.restartTarget(ctx, ref, namespace, map[string]string{
+		keys.RestartedBy:   scheduleName(schedule),
+		keys.RestartReason: string(execution.Reason),
+		keys.ExecutionID:   execution.ID,
+	}, execution.DryRun)
+	endSpan(span, err)
+	if errors.Is(err, errUnsupportedKind) {
+		logger.Error(err, "Unsupported target kind")
+		r.Recorder.Event(schedule, "Warning", "UnsupportedKind",
+			fmt.Sprintf("Unsupported target kind: %s", ref.Kind))
+		return nil, err
+	}
+	if apierrors.IsNotFound(err) {
+		r.Recorder.Event(schedule, "Warning", "TargetNotFound",
+			fmt.Sprintf("%s %s/%s not found", ref.Kind, namespace, ref.Name))
+		return nil, err
+	}
+	if err != nil {
+		return target, err
+	}
 
-	if err := r.Update(ctx, &statefulSet); err != nil {
-		logger.Error(err, "Failed to update StatefulSet")
-		return err
+	if execution.DryRun {
+		r.Recorder.Event(schedule, "Normal", "DryRunRestart",
+			fmt.Sprintf("Would restart %s %s/%s", ref.Kind, namespace, ref.Name))
+		return target, nil
 	}
+	r.Recorder.Event(schedule, "Normal", "RestartTriggered",
+		fmt.Sprintf("Restarted %s %s/%s", ref.Kind, namespace, ref.Name))
+	r.Recorder.Event(target, "Normal", "RestartTriggered",
+		fmt.Sprintf("Restarted by %s %s", scheduleKind(schedule), scheduleName(schedule)))
+	return target, nil
+}
 
-	logger.Info("Successfully restarted StatefulSet")
-	return nil
+// patchStatus sends the difference between base and schedule as a merge patch
+// on the status subresource, so concurrent writers to other fields are not
+// overwritten and no resourceVersion conflict can occur. The Ready condition
+// is brought up to date with the other conditions first.
+func (r *RestartScheduleReconciler) patchStatus(ctx context.Context, schedule, base scheduleObject) error {
+	applyReadyCondition(schedule)
+	return retry.OnError(retry.DefaultBackoff, isRetryable, func() error {
+		return r.Status().Patch(ctx, schedule, client.MergeFrom(base), client.FieldOwner(fieldManager))
+	})
 }
 
-func (r *RestartScheduleReconciler) restartDaemonSet(ctx context.Context, name, namespace string) error {
-	logger := r.Log.WithValues("kind", "DaemonSet", "name", name, "namespace", namespace)
-	logger.Info("Restarting DaemonSet")
+// applyCondition sets the condition on the schedule like
+// meta.SetStatusCondition does: lastTransitionTime only changes along with
+// the status, so a new reason or message keeps the time of the transition.
+// The condition is stamped with the generation of the schedule.
+func applyCondition(schedule scheduleObject, condition metav1.Condition) {
+	condition.ObservedGeneration = schedule.GetGeneration()
+	meta.SetStatusCondition(&schedule.GetScheduleStatus().Conditions, condition)
+}
 
-	var daemonSet appsv1.DaemonSet
-	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &daemonSet); err != nil {
-		logger.Error(err, "Failed to get DaemonSet")
-		return err
+// applyReadyCondition summarizes the other conditions in the Ready condition.
+// A schedule is ready when it is valid, all its targets exist and its last
+// run did not fail. Ready is only reported once the schedule has been
+// validated.
+func applyReadyCondition(schedule scheduleObject) {
+	conditions := schedule.GetScheduleStatus().Conditions
+	valid := meta.FindStatusCondition(conditions, "Valid")
+	if valid == nil {
+		return
 	}
 
-	if daemonSet.Spec.Template.ObjectMeta.Annotations == nil {
-		daemonSet.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+	ready := metav1.Condition{
+		Type:    "Ready",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Ready",
+		Message: "Schedule is registered and all targets exist",
 	}
+	found := meta.FindStatusCondition(conditions, "TargetFound")
+	failed := meta.FindStatusCondition(conditions, "RestartFailed")
+	switch {
+	case valid.Status != metav1.ConditionTrue:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = valid.Reason
+		ready.Message = valid.Message
+	case valid.Reason == "Suspended":
+		// A suspended schedule does not run, so it is not ready, although
+		// nothing is wrong with it.
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "Suspended"
+		ready.Message = valid.Message
+	case found != nil && found.Status == metav1.ConditionFalse:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = found.Reason
+		if ready.Reason == "NotFound" {
+			ready.Reason = "TargetNotFound"
+		}
+		ready.Message = found.Message
+	case failed != nil && failed.Status == metav1.ConditionTrue:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "RestartFailed"
+		ready.Message = failed.Message
+	}
+	applyCondition(schedule, ready)
+}
 
-	daemonSet.Spec.Template.ObjectMeta.Annotations["restart-operator.k8s/restartedAt"] = time.Now().Format(time.RFC3339)
-
-	if err := r.Update(ctx, &daemonSet); err != nil {
-		logger.Error(err, "Failed to update DaemonSet")
+func (r *RestartScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.RestartSchedule{}, targetIndexKey, indexTargets); err != nil {
 		return err
 	}
 
-	logger.Info("Successfully restarted DaemonSet")
-	return nil
-}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.RestartSchedule{}, policyIndexKey, indexPolicyRef); err != nil {
+		return err
+	}
 
-func applyCondition(schedule *v1alpha1.RestartSchedule, condition metav1.Condition) {
-	currentConditions := schedule.Status.Conditions
-	for i, existingCondition := range currentConditions {
-		if existingCondition.Type == condition.Type {
-			if existingCondition.Status == condition.Status &&
-				existingCondition.Reason == condition.Reason &&
-				existingCondition.Message == condition.Message {
-				return
-			}
-			currentConditions[i] = condition
-			schedule.Status.Conditions = currentConditions
-			return
-		}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.RestartSchedule{}, calendarIndexKey, indexCalendarRefs); err != nil {
+		return err
 	}
 
-	schedule.Status.Conditions = append(schedule.Status.Conditions, condition)
-}
+	// The calendar index is shared with the ClusterRestartSchedule
+	// reconciler, which is set up after this one.
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&v1alpha1.RestartCalendar{}, calendarConfigMapIndexKey, indexCalendarConfigMap); err != nil {
+		return err
+	}
 
-func (r *RestartScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	newList := func() client.ObjectList { return &v1alpha1.RestartScheduleList{} }
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.RestartSchedule{}).
+		Watches(&v1alpha1.RestartTargetGrant{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForGrant)).
+		Watches(&v1alpha1.RestartPolicy{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForPolicy(newList))).
+		Watches(&v1alpha1.ClusterRestartPolicy{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForPolicy(newList))).
+		Watches(&v1alpha1.RestartCalendar{}, handler.EnqueueRequestsFromMapFunc(r.schedulesForCalendar(newList)))
+	bldr = r.watchCalendarConfigMaps(bldr, newList)
+	return r.watchTargets(bldr, newList).
 		Complete(r)
 }
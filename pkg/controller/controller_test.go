@@ -9,15 +9,20 @@ import (
 	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
 	"github.com/robfig/cron/v3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -26,6 +31,71 @@ func init() {
 	logf.SetLogger(zap.New(zap.UseDevMode(true)))
 }
 
+// newTestReconciler returns a reconciler backed by a fake client holding
+// objs, with the status subresources and field indexes the manager sets up.
+func newTestReconciler(objs ...client.Object) *RestartScheduleReconciler {
+	return newInterceptedTestReconciler(interceptor.Funcs{}, objs...)
+}
+
+// newInterceptedTestReconciler is newTestReconciler with calls to the fake
+// client going through funcs.
+func newInterceptedTestReconciler(funcs interceptor.Funcs, objs ...client.Object) *RestartScheduleReconciler {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = appsv1.AddToScheme(s)
+	_ = batchv1.AddToScheme(s)
+	_ = corev1.AddToScheme(s)
+
+	mockClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(objs...).
+		WithStatusSubresource(&v1alpha1.RestartSchedule{}, &v1alpha1.ClusterRestartSchedule{}).
+		WithIndex(&v1alpha1.RestartSchedule{}, targetIndexKey, indexTargets).
+		WithIndex(&v1alpha1.RestartSchedule{}, policyIndexKey, indexPolicyRef).
+		WithIndex(&v1alpha1.RestartSchedule{}, calendarIndexKey, indexCalendarRefs).
+		WithIndex(&v1alpha1.ClusterRestartSchedule{}, targetIndexKey, indexTargets).
+		WithIndex(&v1alpha1.ClusterRestartSchedule{}, policyIndexKey, indexPolicyRef).
+		WithIndex(&v1alpha1.ClusterRestartSchedule{}, calendarIndexKey, indexCalendarRefs).
+		WithIndex(&v1alpha1.RestartCalendar{}, calendarConfigMapIndexKey, indexCalendarConfigMap).
+		WithInterceptorFuncs(funcs).
+		Build()
+
+	return &RestartScheduleReconciler{
+		Client:      mockClient,
+		Scheme:      s,
+		Recorder:    record.NewFakeRecorder(20),
+		Log:         logf.Log.WithName("test-logger"),
+		cron:        cron.New(),
+		scheduleIDs: make(map[string]cron.EntryID),
+	}
+}
+
+// newTestSchedule returns an hourly RestartSchedule of the Deployment
+// test-deployment in the default namespace.
+func newTestSchedule() *v1alpha1.RestartSchedule {
+	return &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{
+				Kind: "Deployment",
+				Name: "test-deployment",
+			},
+		},
+	}
+}
+
+// pollFast makes rollouts, soaks and hook Jobs poll every millisecond until
+// the test ends.
+func pollFast(t *testing.T) {
+	previous := rolloutPollInterval
+	rolloutPollInterval = time.Millisecond
+	t.Cleanup(func() { rolloutPollInterval = previous })
+}
+
 func TestCronScheduleValidation(t *testing.T) {
 	s := runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(s)
@@ -63,6 +133,53 @@ func TestCronScheduleValidation(t *testing.T) {
 	}
 }
 
+func TestParseScheduleInTimeZone(t *testing.T) {
+	spec := &v1alpha1.RestartScheduleSpec{Schedule: "0 3 * * *", TimeZone: "Asia/Tokyo"}
+	schedule, err := ParseSchedule(spec)
+	assert.NoError(t, err)
+
+	next := schedule.Next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 1, 1, 18, 0, 0, 0, time.UTC), next.UTC())
+
+	spec.TimeZone = "Mars/Olympus_Mons"
+	_, err = ParseSchedule(spec)
+	assert.ErrorContains(t, err, "invalid time zone")
+}
+
+func TestActiveBlackoutWindow(t *testing.T) {
+	spec := &v1alpha1.RestartScheduleSpec{
+		BlackoutWindows: []v1alpha1.BlackoutWindow{
+			{Name: "business-hours", Schedule: "0 9 * * 1-5", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+		},
+	}
+
+	tests := []struct {
+		time   time.Time
+		window string
+	}{
+		{time: time.Date(2025, 1, 6, 9, 0, 0, 0, time.Local), window: "business-hours"},
+		{time: time.Date(2025, 1, 6, 16, 59, 0, 0, time.Local), window: "business-hours"},
+		{time: time.Date(2025, 1, 6, 17, 0, 0, 0, time.Local), window: ""},
+		{time: time.Date(2025, 1, 6, 8, 59, 0, 0, time.Local), window: ""},
+		{time: time.Date(2025, 1, 5, 12, 0, 0, 0, time.Local), window: ""},
+	}
+	for _, tt := range tests {
+		window, err := activeBlackoutWindow(spec, tt.time)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.window, window, tt.time.String())
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	assert.Zero(t, jitterDelay(nil))
+	jitter := &metav1.Duration{Duration: time.Minute}
+	for range 100 {
+		delay := jitterDelay(jitter)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.Less(t, delay, time.Minute)
+	}
+}
+
 func TestTargetNamespaceResolution(t *testing.T) {
 	s := runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(s)
@@ -89,7 +206,7 @@ func TestTargetNamespaceResolution(t *testing.T) {
 		},
 		Spec: v1alpha1.RestartScheduleSpec{
 			Schedule: "0 * * * *",
-			TargetRef: v1alpha1.TargetRef{
+			TargetRef: &v1alpha1.TargetRef{
 				Kind:      "Deployment",
 				Name:      "test-deployment",
 				Namespace: "explicit-namespace",
@@ -104,7 +221,7 @@ func TestTargetNamespaceResolution(t *testing.T) {
 		},
 		Spec: v1alpha1.RestartScheduleSpec{
 			Schedule: "0 * * * *",
-			TargetRef: v1alpha1.TargetRef{
+			TargetRef: &v1alpha1.TargetRef{
 				Kind: "Deployment",
 				Name: "test-deployment",
 			},
@@ -166,7 +283,8 @@ func TestDeploymentRestart(t *testing.T) {
 		mu:          sync.RWMutex{},
 	}
 
-	err := reconciler.restartDeployment(context.Background(), "test-deployment", "default")
+	_, err := reconciler.restartTarget(context.Background(),
+		v1alpha1.TargetRef{Kind: "Deployment", Name: "test-deployment"}, "default", nil, false)
 	assert.NoError(t, err)
 
 	updatedDeployment := &appsv1.Deployment{}
@@ -185,6 +303,73 @@ func TestDeploymentRestart(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestUpcomingRuns(t *testing.T) {
+	spec := &v1alpha1.RestartScheduleSpec{
+		Schedule: "0 3 * * *",
+		TimeZone: "Asia/Tokyo",
+		Jitter:   &metav1.Duration{Duration: 10 * time.Minute},
+		BlackoutWindows: []v1alpha1.BlackoutWindow{
+			{Name: "weekend", Schedule: "0 0 * * 6", Duration: metav1.Duration{Duration: 48 * time.Hour}},
+		},
+	}
+	schedule, err := ParseSchedule(spec)
+	require.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Thursday, 2 January 2025: Saturday and Sunday are left out.
+	runs := upcomingRuns(spec, schedule, nil, time.Date(2025, 1, 2, 12, 0, 0, 0, tokyo))
+	require.Len(t, runs, defaultPreviewCount)
+	for i, day := range []int{3, 6, 7} {
+		assert.Equal(t, time.Date(2025, 1, day, 3, 0, 0, 0, tokyo), runs[i].Time.In(tokyo))
+		require.NotNil(t, runs[i].LatestTime)
+		assert.Equal(t, 10*time.Minute, runs[i].LatestTime.Sub(runs[i].Time.Time))
+	}
+
+	spec.PreviewCount = ptr.To[int32](0)
+	assert.Empty(t, upcomingRuns(spec, schedule, nil, time.Now()))
+
+	// A schedule whose every run falls into a blackout window has none.
+	spec.PreviewCount = nil
+	spec.BlackoutWindows = []v1alpha1.BlackoutWindow{
+		{Name: "always", Schedule: "0 0 * * *", Duration: metav1.Duration{Duration: 24 * time.Hour}},
+	}
+	assert.Empty(t, upcomingRuns(spec, schedule, nil, time.Now()))
+}
+
+func TestNextRunRespectsBlackoutWindows(t *testing.T) {
+	spec := &v1alpha1.RestartScheduleSpec{
+		Schedule: "0 3 * * *",
+		TimeZone: "Asia/Tokyo",
+		Jitter:   &metav1.Duration{Duration: 30 * time.Minute},
+		BlackoutWindows: []v1alpha1.BlackoutWindow{
+			// Starts within the jitter of the run on Friday.
+			{Name: "friday-deploys", Schedule: "15 3 * * 5", Duration: metav1.Duration{Duration: time.Hour}},
+			{Name: "weekend", Schedule: "0 0 * * 6", Duration: metav1.Duration{Duration: 48 * time.Hour}},
+		},
+	}
+	schedule, err := ParseSchedule(spec)
+	require.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	// Thursday, 2 January 2025: the runs on Friday, Saturday and Sunday may
+	// be skipped, so the next one is on Monday.
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, tokyo)
+	next := nextRun(spec, schedule, nil, now)
+	require.NotNil(t, next)
+	assert.Equal(t, time.Date(2025, 1, 6, 3, 0, 0, 0, tokyo), next.In(tokyo))
+
+	runs := upcomingRuns(spec, schedule, nil, now)
+	require.NotEmpty(t, runs)
+	assert.Equal(t, *next, runs[0].Time.Time)
+
+	spec.BlackoutWindows = []v1alpha1.BlackoutWindow{
+		{Name: "always", Schedule: "0 0 * * *", Duration: metav1.Duration{Duration: 24 * time.Hour}},
+	}
+	assert.Nil(t, nextRun(spec, schedule, nil, now))
+}
+
 func TestReconcileScheduleLifecycle(t *testing.T) {
 	s := runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(s)
@@ -197,7 +382,7 @@ func TestReconcileScheduleLifecycle(t *testing.T) {
 		},
 		Spec: v1alpha1.RestartScheduleSpec{
 			Schedule: "0 * * * *",
-			TargetRef: v1alpha1.TargetRef{
+			TargetRef: &v1alpha1.TargetRef{
 				Kind:      "Deployment",
 				Name:      "test-deployment",
 				Namespace: "default",
@@ -254,6 +439,30 @@ func TestReconcileScheduleLifecycle(t *testing.T) {
 	assert.False(t, exists)
 }
 
+func TestInvalidScheduleIsUnscheduled(t *testing.T) {
+	reconciler := newTestReconciler(newTestSchedule())
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-schedule", Namespace: "default"}}
+
+	_, err := reconciler.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Contains(t, reconciler.scheduleIDs, req.String())
+
+	var schedule v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), req.NamespacedName, &schedule))
+	require.NotNil(t, schedule.Status.NextScheduledTime)
+	schedule.Spec.Schedule = "not a schedule"
+	require.NoError(t, reconciler.Update(context.Background(), &schedule))
+
+	_, err = reconciler.Reconcile(context.Background(), req)
+	assert.Error(t, err)
+	assert.NotContains(t, reconciler.scheduleIDs, req.String(), "the previous schedule must no longer fire")
+
+	require.NoError(t, reconciler.Get(context.Background(), req.NamespacedName, &schedule))
+	assert.Nil(t, schedule.Status.NextScheduledTime)
+	assert.Empty(t, schedule.Status.UpcomingRuns)
+	assert.True(t, meta.IsStatusConditionFalse(schedule.Status.Conditions, "Valid"))
+}
+
 func TestApplyCondition(t *testing.T) {
 	schedule := &v1alpha1.RestartSchedule{}
 
@@ -290,6 +499,63 @@ func TestApplyCondition(t *testing.T) {
 
 	applyCondition(schedule, condition3)
 	assert.Len(t, schedule.Status.Conditions, 2)
+
+	// A new message keeps the time of the last transition.
+	schedule.Generation = 2
+	transitioned := schedule.Status.Conditions[0].LastTransitionTime
+	applyCondition(schedule, metav1.Condition{
+		Type:               "Test",
+		Status:             metav1.ConditionFalse,
+		Reason:             "AnotherReason",
+		Message:            "Updated message",
+		LastTransitionTime: metav1.NewTime(transitioned.Add(time.Hour)),
+	})
+	assert.Equal(t, "Updated message", schedule.Status.Conditions[0].Message)
+	assert.Equal(t, transitioned, schedule.Status.Conditions[0].LastTransitionTime)
+	assert.Equal(t, int64(2), schedule.Status.Conditions[0].ObservedGeneration)
+}
+
+func TestApplyReadyCondition(t *testing.T) {
+	ready := func(conditions ...metav1.Condition) *metav1.Condition {
+		schedule := &v1alpha1.RestartSchedule{}
+		for _, condition := range conditions {
+			applyCondition(schedule, condition)
+		}
+		applyReadyCondition(schedule)
+		return meta.FindStatusCondition(schedule.Status.Conditions, "Ready")
+	}
+	valid := metav1.Condition{Type: "Valid", Status: metav1.ConditionTrue, Reason: "ScheduleValid"}
+	found := metav1.Condition{Type: "TargetFound", Status: metav1.ConditionTrue, Reason: "Found"}
+	succeeded := metav1.Condition{Type: "RestartFailed", Status: metav1.ConditionFalse, Reason: "RestartSucceeded"}
+
+	assert.Nil(t, ready(), "Ready is only reported once the schedule has been validated")
+
+	condition := ready(valid, found, succeeded)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "Ready", condition.Reason)
+
+	suspended := metav1.Condition{Type: "Valid", Status: metav1.ConditionTrue, Reason: "Suspended", Message: "Schedule is valid and suspended"}
+	condition = ready(suspended, found)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "Suspended", condition.Reason)
+	assert.Equal(t, "Schedule is valid and suspended", condition.Message)
+
+	invalid := metav1.Condition{Type: "Valid", Status: metav1.ConditionFalse, Reason: "PolicyNotFound", Message: "RestartPolicy nightly not found"}
+	condition = ready(invalid, found)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "PolicyNotFound", condition.Reason)
+	assert.Equal(t, "RestartPolicy nightly not found", condition.Message)
+
+	missing := metav1.Condition{Type: "TargetFound", Status: metav1.ConditionFalse, Reason: "NotFound", Message: "Not found: Deployment default/api"}
+	condition = ready(valid, missing, succeeded)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "TargetNotFound", condition.Reason)
+
+	failed := metav1.Condition{Type: "RestartFailed", Status: metav1.ConditionTrue, Reason: "RolloutFailed", Message: "Restart failed: timeout"}
+	condition = ready(valid, found, failed)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, "RestartFailed", condition.Reason)
+	assert.Equal(t, "Restart failed: timeout", condition.Message)
 }
 
 func (r *RestartScheduleReconciler) getTargetNamespace(schedule *v1alpha1.RestartSchedule) string {
@@ -0,0 +1,707 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultRetryAttempts and defaultBackoff mirror the defaults declared on
+	// RetryPolicy, for objects that were stored without them.
+	defaultRetryAttempts = 3
+	defaultBackoff       = 30 * time.Second
+
+	// maxBackoff caps the delay between retries, which doubles after every
+	// failed attempt.
+	maxBackoff = 10 * time.Minute
+
+	defaultSoakDuration = 5 * time.Minute
+)
+
+// restartStage is one step of a run. Its targets are restarted together and,
+// when waitForRollout is set, have to finish rolling out before the run moves
+// on. Stages without a name are not reported individually in status.
+type restartStage struct {
+	name           string
+	targets        []v1alpha1.TargetRef
+	waitForRollout bool
+	soakDuration   time.Duration
+}
+
+// restartPlan turns the spec of a schedule into the ordered stages of a run.
+// With a health gate every stage waits for its rollout, so the gate is only
+// soaked once all targets run their new pods.
+func restartPlan(spec *v1alpha1.RestartScheduleSpec) []restartStage {
+	stages := targetStages(spec)
+	if spec.HealthGate != nil && spec.HealthGate.Prometheus != nil {
+		for i := range stages {
+			stages[i].waitForRollout = true
+		}
+	}
+	return stages
+}
+
+func targetStages(spec *v1alpha1.RestartScheduleSpec) []restartStage {
+	if len(spec.Stages) > 0 {
+		stages := make([]restartStage, 0, len(spec.Stages))
+		for _, stage := range spec.Stages {
+			stages = append(stages, restartStage{
+				name:           stage.Name,
+				targets:        stage.Targets,
+				waitForRollout: true,
+			})
+		}
+		return stages
+	}
+	if len(spec.Targets) > 0 && spec.Canary != nil {
+		return canaryPlan(spec.Targets, spec.Canary)
+	}
+	if len(spec.Targets) > 0 {
+		return []restartStage{{targets: spec.Targets}}
+	}
+	if spec.TargetRef != nil {
+		return []restartStage{{targets: []v1alpha1.TargetRef{*spec.TargetRef}}}
+	}
+	return nil
+}
+
+// canaryPlan splits the targets into a canary stage, which has to roll out
+// and stay healthy for the soak period, and a stage with the remaining targets.
+func canaryPlan(targets []v1alpha1.TargetRef, canary *v1alpha1.CanaryPolicy) []restartStage {
+	count := 1
+	switch {
+	case canary.Count != nil:
+		count = int(*canary.Count)
+	case canary.Percentage != nil:
+		count = (len(targets)*int(*canary.Percentage) + 99) / 100
+	}
+	count = max(1, min(count, len(targets)))
+
+	soakDuration := defaultSoakDuration
+	if canary.SoakDuration != nil {
+		soakDuration = canary.SoakDuration.Duration
+	}
+
+	stages := []restartStage{{
+		name:           "canary",
+		targets:        targets[:count],
+		waitForRollout: true,
+		soakDuration:   soakDuration,
+	}}
+	if count < len(targets) {
+		stages = append(stages, restartStage{
+			name:           "remaining",
+			targets:        targets[count:],
+			waitForRollout: true,
+		})
+	}
+	return stages
+}
+
+// runScheduledRestart runs a scheduled restart of the RestartSchedule with the
+// given key.
+func (r *RestartScheduleReconciler) runScheduledRestart(ctx context.Context, key types.NamespacedName, cronSchedule cron.Schedule) {
+	r.run(ctx, &v1alpha1.RestartSchedule{}, key, cronSchedule, v1alpha1.ScheduledRestart)
+}
+
+// run is invoked by the cron scheduler and for manual runs. It reads the
+// schedule with the given key into schedule, runs its hooks and stages in
+// order, retrying failed restarts according to the retry policy, and records
+// every attempt in the schedule status. The run is canceled when the schedule
+// is unscheduled.
+func (r *RestartScheduleReconciler) run(
+	ctx context.Context,
+	schedule scheduleObject,
+	key types.NamespacedName,
+	cronSchedule cron.Schedule,
+	reason v1alpha1.RestartReason,
+) {
+	kind := scheduleKind(schedule)
+	logger := r.Log.WithValues(
+		strings.ToLower(kind), key,
+		"execution", time.Now().Format(time.RFC3339),
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(r.runContext(key.String()), cancel)()
+
+	ctx, span := r.startSpan(ctx, "RestartExecution", key)
+	defer span.End()
+
+	if err := r.Get(ctx, key, schedule); err != nil {
+		logger.Error(err, "Failed to get latest "+kind+" for scheduled restart")
+		setSpanError(span, err)
+		return
+	}
+	if !schedule.GetDeletionTimestamp().IsZero() {
+		logger.Info("Skipping scheduled restart of deleted " + kind)
+		return
+	}
+	suspended := schedule.GetScheduleSpec().Suspend
+	if suspended && reason == v1alpha1.ScheduledRestart {
+		logger.Info("Skipping scheduled restart of suspended " + kind)
+		return
+	}
+
+	if !r.startRun(key.String()) {
+		logger.Info("Skipping restart of " + kind + " while its previous run is in progress")
+		r.recordAlreadyRunning(ctx, schedule, reason)
+		return
+	}
+	defer r.finishRun(key.String())
+
+	execution := &v1alpha1.RestartExecution{
+		ID:        string(uuid.NewUUID()),
+		Reason:    reason,
+		StartTime: metav1.Now(),
+		Result:    v1alpha1.ExecutionRunning,
+		DryRun:    r.DryRun || schedule.GetScheduleSpec().DryRun,
+	}
+
+	failureReason, failure := r.execute(ctx, schedule, execution)
+
+	if ctx.Err() != nil {
+		logger.Info("Canceled run of unscheduled " + kind)
+		return
+	}
+
+	now := metav1.Now()
+	execution.CompletionTime = &now
+	var lastSuccessfulTime *metav1.Time
+	var restartFailed *metav1.Condition
+
+	var skipped *skipError
+	switch {
+	case failure == nil && execution.DryRun:
+		// A dry run says nothing about the health of the targets, so it
+		// leaves lastSuccessfulTime and the RestartFailed condition alone.
+		execution.Result = v1alpha1.ExecutionSucceeded
+		execution.Message = dryRunMessage(schedule)
+		r.Recorder.Event(schedule, "Normal", "DryRunCompleted", execution.Message)
+	case failure == nil:
+		execution.Result = v1alpha1.ExecutionSucceeded
+		lastSuccessfulTime = &now
+		restartFailed = &metav1.Condition{
+			Type:               "RestartFailed",
+			Status:             metav1.ConditionFalse,
+			Reason:             "RestartSucceeded",
+			Message:            "The last restart succeeded",
+			LastTransitionTime: now,
+		}
+		r.Recorder.Event(schedule, "Normal", "RestartCompleted", "Restart completed")
+	case errors.As(failure, &skipped):
+		logger.Info("Skipped scheduled restart", "reason", skipped.reason, "message", skipped.message)
+		execution.Result = v1alpha1.ExecutionSkipped
+		execution.Message = skipped.message
+	case execution.DryRun:
+		execution.Result = v1alpha1.ExecutionFailed
+		execution.Message = failure.Error()
+		r.Recorder.Event(schedule, "Warning", "DryRunFailed", fmt.Sprintf("Dry run failed: %v", failure))
+		setSpanError(span, failure)
+	default:
+		execution.Result = v1alpha1.ExecutionFailed
+		execution.Message = failure.Error()
+		restartFailed = &metav1.Condition{
+			Type:               "RestartFailed",
+			Status:             metav1.ConditionTrue,
+			Reason:             failureReason,
+			Message:            fmt.Sprintf("Restart failed: %v", failure),
+			LastTransitionTime: now,
+		}
+		r.Recorder.Event(schedule, "Warning", "RestartFailed", fmt.Sprintf("Restart failed: %v", failure))
+		setSpanError(span, failure)
+	}
+	span.SetAttributes(attribute.String("restart.result", string(execution.Result)))
+
+	var next *time.Time
+	var upcoming []v1alpha1.UpcomingRun
+	if !suspended {
+		// The preview leaves out calendars that cannot be read.
+		holidays, _ := r.loadHolidays(ctx, schedule.GetScheduleSpec())
+		now := time.Now()
+		next = nextRun(schedule.GetScheduleSpec(), cronSchedule, holidays, now)
+		upcoming = upcomingRuns(schedule.GetScheduleSpec(), cronSchedule, holidays, now)
+	}
+
+	// The run may have taken a while, so its results are applied to the
+	// latest status rather than to the one read when it started.
+	err := r.updateStatus(ctx, schedule, func(latest scheduleObject) {
+		status := latest.GetScheduleStatus()
+		status.LastExecution = execution
+		if lastSuccessfulTime != nil {
+			status.LastSuccessfulTime = lastSuccessfulTime
+		}
+		if restartFailed != nil {
+			applyCondition(latest, *restartFailed)
+		}
+		recordHistory(status, execution)
+		// The next runs of a spec that changed during the run are left to
+		// the reconcile of the change.
+		if latest.GetGeneration() == schedule.GetGeneration() {
+			status.NextScheduledTime = nil
+			if next != nil {
+				status.NextScheduledTime = &metav1.Time{Time: *next}
+			}
+			status.UpcomingRuns = upcoming
+		}
+	})
+	if err != nil {
+		logger.Error(err, "Failed to update status after restart")
+	}
+
+	if err := r.callWebhooks(ctx, schedule, v1alpha1.PostRestartHook, execution); err != nil {
+		logger.Error(err, "Failed to call post-restart webhooks")
+	}
+	// The final results share their names with the notification events.
+	r.notify(ctx, schedule, v1alpha1.NotificationEvent(execution.Result), execution)
+
+	switch execution.Result {
+	case v1alpha1.ExecutionSucceeded:
+		r.publishExecution(ctx, schedule, RestartSucceededEvent, execution)
+	case v1alpha1.ExecutionSkipped:
+		r.publishExecution(ctx, schedule, RestartSkippedEvent, execution)
+	default:
+		r.publishExecution(ctx, schedule, RestartFailedEvent, execution)
+	}
+	if next != nil {
+		r.publish(ctx, schedule, RestartScheduledEvent, restartEventData{ScheduledTime: next})
+	}
+}
+
+// startRun marks a run of the schedule registered under key as in progress.
+// It returns false if another run of the schedule already is.
+func (r *RestartScheduleReconciler) startRun(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running.Has(key) {
+		return false
+	}
+	if r.running == nil {
+		r.running = sets.New[string]()
+	}
+	r.running.Insert(key)
+	return true
+}
+
+// finishRun marks the run of the schedule registered under key as done.
+func (r *RestartScheduleReconciler) finishRun(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running.Delete(key)
+}
+
+// recordAlreadyRunning records a run that was skipped because the previous
+// run of the schedule is still in progress. Only the history is updated, so
+// status.lastExecution keeps showing the run in progress.
+func (r *RestartScheduleReconciler) recordAlreadyRunning(ctx context.Context, schedule scheduleObject, reason v1alpha1.RestartReason) {
+	now := metav1.Now()
+	execution := &v1alpha1.RestartExecution{
+		ID:             string(uuid.NewUUID()),
+		Reason:         reason,
+		StartTime:      now,
+		CompletionTime: &now,
+		Result:         v1alpha1.ExecutionSkipped,
+		Message:        "Restart skipped because the previous run is still in progress",
+		DryRun:         r.DryRun || schedule.GetScheduleSpec().DryRun,
+	}
+	err := r.updateStatus(ctx, schedule, func(latest scheduleObject) {
+		recordHistory(latest.GetScheduleStatus(), execution)
+	})
+	if err != nil {
+		r.Log.Error(err, "Failed to record skipped run",
+			"restartschedule", client.ObjectKeyFromObject(schedule))
+	}
+	r.Recorder.Event(schedule, "Normal", "AlreadyRunning", execution.Message)
+	r.publishExecution(ctx, schedule, RestartSkippedEvent, execution)
+}
+
+// dryRunMessage lists the targets a dry run would have restarted.
+func dryRunMessage(schedule scheduleObject) string {
+	targets := scheduleTargets(schedule.GetScheduleSpec())
+	if len(targets) == 0 {
+		return "Dry run: no targets would have been restarted"
+	}
+	names := make([]string, 0, len(targets))
+	for _, ref := range targets {
+		names = append(names, fmt.Sprintf("%s %s/%s", ref.Kind, targetNamespace(ref, schedule.GetNamespace()), ref.Name))
+	}
+	return "Dry run: would have restarted " + strings.Join(names, ", ")
+}
+
+// historyLimit is the number of runs kept in status.history.
+const historyLimit = 10
+
+// recordHistory adds the finished execution to the front of the history and
+// drops the oldest entries beyond historyLimit.
+func recordHistory(status *v1alpha1.RestartScheduleStatus, execution *v1alpha1.RestartExecution) {
+	entry := v1alpha1.ExecutionSummary{
+		ID:             execution.ID,
+		Reason:         execution.Reason,
+		StartTime:      execution.StartTime,
+		CompletionTime: execution.CompletionTime,
+		Result:         execution.Result,
+		Message:        execution.Message,
+		DryRun:         execution.DryRun,
+	}
+	status.History = append([]v1alpha1.ExecutionSummary{entry}, status.History...)
+	if len(status.History) > historyLimit {
+		status.History = status.History[:historyLimit]
+	}
+}
+
+// execute runs the hooks and stages of one execution. On failure it returns
+// the condition reason to report along with the error.
+func (r *RestartScheduleReconciler) execute(ctx context.Context, schedule scheduleObject, execution *v1alpha1.RestartExecution) (string, error) {
+	key := client.ObjectKeyFromObject(schedule)
+	logger := r.Log.WithValues("restartschedule", key)
+
+	if err := r.applyPolicy(ctx, schedule); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "PolicyNotFound", err
+		}
+		var invalid *invalidPolicyError
+		if errors.As(err, &invalid) {
+			return "InvalidPolicy", err
+		}
+		return "PolicyFailed", err
+	}
+	hooks := schedule.GetScheduleSpec().Hooks
+	if execution.DryRun {
+		// Hook Jobs cannot be dry-run, so they are left out.
+		hooks = nil
+	}
+
+	window, err := activeBlackoutWindow(schedule.GetScheduleSpec(), time.Now())
+	if err != nil {
+		return "InvalidBlackoutWindow", err
+	}
+	if window != "" && execution.Reason == v1alpha1.ScheduledRestart {
+		return "BlackoutWindow", &skipError{
+			reason:  "BlackoutWindow",
+			message: fmt.Sprintf("Restart skipped during blackout window %q", window),
+		}
+	}
+	if execution.Reason == v1alpha1.ScheduledRestart {
+		if reason, err := r.checkHolidays(ctx, schedule.GetScheduleSpec()); err != nil {
+			return reason, err
+		}
+	}
+
+	if err := r.resolveTargets(ctx, schedule); err != nil {
+		var skipped *skipError
+		if errors.As(err, &skipped) {
+			return skipped.reason, err
+		}
+		return "TargetSelectionFailed", err
+	}
+	if err := r.authorizeTargets(ctx, schedule); err != nil {
+		var forbidden *forbiddenError
+		if errors.As(err, &forbidden) {
+			return "Forbidden", err
+		}
+		return "AuthorizationFailed", err
+	}
+
+	if err := r.callWebhooks(ctx, schedule, v1alpha1.PreRestartHook, execution); err != nil {
+		var skipped *skipError
+		if errors.As(err, &skipped) {
+			return skipped.reason, err
+		}
+		return "WebhookFailed", err
+	}
+
+	var healthGate *v1alpha1.PrometheusHealthGate
+	if schedule.GetScheduleSpec().HealthGate != nil {
+		healthGate = schedule.GetScheduleSpec().HealthGate.Prometheus
+	}
+	if healthGate != nil {
+		gateCtx, gateSpan := r.startSpan(ctx, "HealthGate", key)
+		err := checkHealthGate(gateCtx, healthGate)
+		endSpan(gateSpan, err)
+		if err != nil {
+			logger.Info("Health gate is closed, skipping the restart", "reason", err.Error())
+			return "HealthGateClosed", &skipError{
+				reason:  "HealthGateClosed",
+				message: fmt.Sprintf("Health gate is closed: %v", err),
+			}
+		}
+	}
+
+	r.notify(ctx, schedule, v1alpha1.NotificationStarted, execution)
+	r.publishExecution(ctx, schedule, RestartStartedEvent, execution)
+
+	if hooks != nil && hooks.PreRestart != nil {
+		hookCtx, hookSpan := r.startSpan(ctx, "Hook", key, attribute.String("hook.phase", string(v1alpha1.PreRestartHook)))
+		err := r.runHook(hookCtx, schedule, v1alpha1.PreRestartHook, hooks.PreRestart, execution)
+		endSpan(hookSpan, err)
+		if err != nil {
+			logger.Error(err, "Pre-restart hook failed, skipping the restart")
+			return "PreRestartHookFailed", err
+		}
+	}
+
+	for _, stage := range restartPlan(schedule.GetScheduleSpec()) {
+		stageIndex := -1
+		if stage.name != "" {
+			execution.Stages = append(execution.Stages, v1alpha1.StageStatus{
+				Name:      stage.name,
+				Result:    v1alpha1.ExecutionRunning,
+				StartTime: metav1.Now(),
+			})
+			stageIndex = len(execution.Stages) - 1
+			r.recordExecution(ctx, schedule, execution)
+			logger.Info("Starting stage", "stage", stage.name)
+		}
+
+		stageCtx, stageSpan := r.startSpan(ctx, "Stage", key, attribute.String("stage.name", stage.name))
+		reason, err := r.runStage(stageCtx, schedule, stage, execution)
+		endSpan(stageSpan, err)
+
+		if stageIndex >= 0 {
+			now := metav1.Now()
+			execution.Stages[stageIndex].CompletionTime = &now
+			execution.Stages[stageIndex].Result = v1alpha1.ExecutionSucceeded
+			if err != nil {
+				execution.Stages[stageIndex].Result = v1alpha1.ExecutionFailed
+				execution.Stages[stageIndex].Message = err.Error()
+			}
+		}
+
+		if err != nil {
+			if stage.name != "" {
+				logger.Error(err, "Stage failed, aborting the remaining stages", "stage", stage.name)
+			}
+			return reason, err
+		}
+	}
+
+	if healthGate != nil && !execution.DryRun {
+		logger.Info("Soaking health gate")
+		soakCtx, soakSpan := r.startSpan(ctx, "HealthGateSoak", key)
+		err := soakHealthGate(soakCtx, healthGate)
+		endSpan(soakSpan, err)
+		if err != nil {
+			logger.Error(err, "Health gate failed after the restart")
+			return "HealthGateFailed", fmt.Errorf("health gate failed after the restart: %w", err)
+		}
+	}
+
+	if hooks != nil && hooks.PostRestart != nil {
+		hookCtx, hookSpan := r.startSpan(ctx, "Hook", key, attribute.String("hook.phase", string(v1alpha1.PostRestartHook)))
+		err := r.runHook(hookCtx, schedule, v1alpha1.PostRestartHook, hooks.PostRestart, execution)
+		endSpan(hookSpan, err)
+		if err != nil {
+			return "PostRestartHookFailed", err
+		}
+	}
+	return "", nil
+}
+
+// runStage restarts the targets of a stage, retrying the ones that failed
+// according to the retry policy, and then waits for their rollout if the
+// stage asks for it. The outcome is recorded as an event on every target:
+// RestartFailed on the targets the failure is attributed to, RestartCompleted
+// on all of them once the stage succeeded. On failure it returns the
+// condition reason to report.
+func (r *RestartScheduleReconciler) runStage(
+	ctx context.Context,
+	schedule scheduleObject,
+	stage restartStage,
+	execution *v1alpha1.RestartExecution,
+) (string, error) {
+	targets := make(map[v1alpha1.TargetRef]*unstructured.Unstructured)
+
+	reason, err := r.restartStageTargets(ctx, schedule, stage, execution, targets)
+	if execution.DryRun {
+		// Nothing was rolled out, so there is nothing to wait for.
+		return reason, err
+	}
+	if err == nil {
+		reason, err = r.awaitStage(ctx, schedule, stage)
+	}
+
+	// Failures that are not attributed to particular targets, such as a
+	// cancelled run, are not reported on any of them.
+	failed := make(map[v1alpha1.TargetRef]bool)
+	var targetsErr *targetsError
+	if errors.As(err, &targetsErr) {
+		for _, ref := range targetsErr.targets {
+			failed[ref] = true
+		}
+	}
+
+	for _, ref := range stage.targets {
+		target, ok := targets[ref]
+		if !ok || target == nil {
+			continue
+		}
+		if failed[ref] {
+			r.Recorder.Event(target, "Warning", "RestartFailed",
+				fmt.Sprintf("Restart by %s %s failed: %v", scheduleKind(schedule), scheduleName(schedule), err))
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		r.Recorder.Event(target, "Normal", "RestartCompleted",
+			fmt.Sprintf("Restart by %s %s completed", scheduleKind(schedule), scheduleName(schedule)))
+	}
+	return reason, err
+}
+
+// restartStageTargets restarts the targets of a stage, retrying the ones that
+// failed according to the retry policy. Every target that could be read is
+// added to targets.
+func (r *RestartScheduleReconciler) restartStageTargets(
+	ctx context.Context,
+	schedule scheduleObject,
+	stage restartStage,
+	execution *v1alpha1.RestartExecution,
+	targets map[v1alpha1.TargetRef]*unstructured.Unstructured,
+) (string, error) {
+	logger := r.Log.WithValues(
+		"restartschedule", client.ObjectKeyFromObject(schedule),
+		"stage", stage.name,
+	)
+	maxAttempts, backoff := retrySettings(schedule.GetScheduleSpec().RetryPolicy)
+
+	pending := stage.targets
+	for attempt := int32(1); ; attempt++ {
+		var failed []v1alpha1.TargetRef
+		var errs []error
+		for _, ref := range pending {
+			target, err := r.restartResource(ctx, schedule, ref, execution)
+			if target != nil {
+				targets[ref] = target
+			}
+			if err != nil {
+				failed = append(failed, ref)
+				errs = append(errs, err)
+			}
+		}
+
+		record := v1alpha1.RestartAttempt{Stage: stage.name, Attempt: attempt, Time: metav1.Now()}
+		err := utilerrors.NewAggregate(errs)
+		if err != nil {
+			logger.Error(err, "Failed to restart resource", "attempt", attempt, "maxAttempts", maxAttempts)
+			record.Error = err.Error()
+		}
+		execution.Attempts = append(execution.Attempts, record)
+
+		if err == nil {
+			return "", nil
+		}
+		if attempt >= maxAttempts {
+			return "AttemptsExhausted", &targetsError{targets: failed, err: err}
+		}
+
+		r.recordExecution(ctx, schedule, execution)
+
+		logger.Info("Retrying restart", "attempt", attempt+1, "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			return "Canceled", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+		pending = failed
+	}
+}
+
+// awaitStage waits for the rollout of the targets of a stage if the stage asks
+// for it, and soaks them afterwards.
+func (r *RestartScheduleReconciler) awaitStage(ctx context.Context, schedule scheduleObject, stage restartStage) (string, error) {
+	if !stage.waitForRollout {
+		return "", nil
+	}
+
+	timeout := defaultRolloutTimeout
+	if schedule.GetScheduleSpec().RolloutTimeout != nil {
+		timeout = schedule.GetScheduleSpec().RolloutTimeout.Duration
+	}
+	key := client.ObjectKeyFromObject(schedule)
+	rolloutCtx, rolloutSpan := r.startSpan(ctx, "WaitForRollout", key, attribute.String("stage.name", stage.name))
+	err := r.waitForRollout(rolloutCtx, stage.targets, schedule.GetNamespace(), timeout)
+	endSpan(rolloutSpan, err)
+	if err != nil {
+		return "RolloutFailed", err
+	}
+	r.publishRolloutComplete(ctx, schedule, stage.targets)
+
+	if stage.soakDuration > 0 {
+		r.Log.Info("Soaking", "restartschedule", client.ObjectKeyFromObject(schedule),
+			"stage", stage.name, "duration", stage.soakDuration.String())
+		soakCtx, soakSpan := r.startSpan(ctx, "Soak", key, attribute.String("stage.name", stage.name))
+		err := r.soak(soakCtx, stage.targets, schedule.GetNamespace(), stage.soakDuration)
+		endSpan(soakSpan, err)
+		if err != nil {
+			return "SoakFailed", err
+		}
+	}
+	return "", nil
+}
+
+// recordExecution publishes the progress of a run that is still going on.
+func (r *RestartScheduleReconciler) recordExecution(ctx context.Context, schedule scheduleObject, execution *v1alpha1.RestartExecution) {
+	err := r.updateStatus(ctx, schedule, func(latest scheduleObject) {
+		latest.GetScheduleStatus().LastExecution = execution.DeepCopy()
+	})
+	if err != nil {
+		r.Log.Error(err, "Failed to record execution progress",
+			"restartschedule", client.ObjectKeyFromObject(schedule))
+	}
+}
+
+// updateStatus applies update to the latest version of the schedule and
+// patches its status. Runs write their status this way, so the conditions
+// and next runs reported by reconciles in the meantime are kept.
+func (r *RestartScheduleReconciler) updateStatus(
+	ctx context.Context,
+	schedule scheduleObject,
+	update func(latest scheduleObject),
+) error {
+	latest := emptySchedule(schedule)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(schedule), latest); err != nil {
+		return err
+	}
+	base := copySchedule(latest)
+	update(latest)
+	return r.patchStatus(ctx, latest, base)
+}
+
+// retrySettings returns the number of attempts per run and the delay before
+// the first retry. Without a retry policy a run is attempted exactly once.
+func retrySettings(policy *v1alpha1.RetryPolicy) (int32, time.Duration) {
+	if policy == nil {
+		return 1, 0
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = defaultRetryAttempts
+	}
+
+	backoff := defaultBackoff
+	if policy.Backoff != nil {
+		backoff = policy.Backoff.Duration
+	}
+	return maxAttempts, backoff
+}
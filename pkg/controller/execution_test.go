@@ -0,0 +1,734 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestRunScheduledRestartRetriesUntilExhausted(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{
+				Kind: "Deployment",
+				Name: "missing-deployment",
+			},
+			RetryPolicy: &v1alpha1.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     &metav1.Duration{Duration: time.Millisecond},
+			},
+		},
+	}
+	reconciler := newTestReconciler(schedule)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, updated.Status.LastExecution.Result)
+	assert.Len(t, updated.Status.LastExecution.Attempts, 3)
+	for i, attempt := range updated.Status.LastExecution.Attempts {
+		assert.Equal(t, int32(i+1), attempt.Attempt)
+		assert.NotEmpty(t, attempt.Error)
+	}
+	assert.NotNil(t, updated.Status.LastExecution.CompletionTime)
+	assert.Nil(t, updated.Status.LastSuccessfulTime)
+	assert.NotNil(t, updated.Status.NextScheduledTime)
+
+	require.Len(t, updated.Status.Conditions, 1)
+	assert.Equal(t, "RestartFailed", updated.Status.Conditions[0].Type)
+	assert.Equal(t, metav1.ConditionTrue, updated.Status.Conditions[0].Status)
+	assert.Equal(t, "AttemptsExhausted", updated.Status.Conditions[0].Reason)
+}
+
+func TestRunScheduledRestartSucceeds(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{
+				Kind: "Deployment",
+				Name: "test-deployment",
+			},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+		},
+	}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+	require.Len(t, updated.Status.LastExecution.Attempts, 1)
+	assert.Empty(t, updated.Status.LastExecution.Attempts[0].Error)
+	assert.NotNil(t, updated.Status.LastSuccessfulTime)
+}
+
+func TestRunSkippedWhileAlreadyRunning(t *testing.T) {
+	schedule := newTestSchedule()
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, deployment)
+	recorder := &eventCollector{}
+	reconciler.Recorder = recorder
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+
+	// A run of the schedule is in progress.
+	require.True(t, reconciler.startRun(key.String()))
+	reconciler.run(context.Background(), &v1alpha1.RestartSchedule{}, key, cronSchedule, v1alpha1.ManualRestart)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.Nil(t, updated.Status.LastExecution, "the run in progress must stay the last execution")
+	require.Len(t, updated.Status.History, 1)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, updated.Status.History[0].Result)
+	assert.Equal(t, v1alpha1.ManualRestart, updated.Status.History[0].Reason)
+	assert.Equal(t, []string{"AlreadyRunning"}, recorder.reasons("test-schedule"))
+	assert.Empty(t, restartedDeployments(t, reconciler.Client))
+
+	// Once it is done, the next run goes ahead.
+	reconciler.finishRun(key.String())
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+	assert.False(t, reconciler.running.Has(key.String()))
+}
+
+func TestRunKeepsStatusWrittenDuringRun(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "missing-deployment"},
+			RetryPolicy: &v1alpha1.RetryPolicy{
+				MaxAttempts: 2,
+				Backoff:     &metav1.Duration{Duration: time.Millisecond},
+			},
+		},
+		Status: v1alpha1.RestartScheduleStatus{
+			Conditions: []metav1.Condition{{
+				Type:   "Valid",
+				Status: metav1.ConditionTrue,
+				Reason: "ScheduleValid",
+			}},
+		},
+	}
+	// A reconcile invalidates the schedule while its first attempt is
+	// recorded.
+	statusPatches := 0
+	reconciler := newInterceptedTestReconciler(interceptor.Funcs{
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			if err := c.SubResource(subResource).Patch(ctx, obj, patch, opts...); err != nil {
+				return err
+			}
+			statusPatches++
+			if statusPatches > 1 {
+				return nil
+			}
+			var current v1alpha1.RestartSchedule
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), &current); err != nil {
+				return err
+			}
+			meta.SetStatusCondition(&current.Status.Conditions, metav1.Condition{
+				Type:    "Valid",
+				Status:  metav1.ConditionFalse,
+				Reason:  "InvalidSchedule",
+				Message: "Invalid schedule",
+			})
+			return c.Status().Update(ctx, &current)
+		},
+	}, schedule)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := client.ObjectKeyFromObject(schedule)
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, updated.Status.LastExecution.Result)
+	assert.Len(t, updated.Status.History, 1)
+
+	valid := meta.FindStatusCondition(updated.Status.Conditions, "Valid")
+	require.NotNil(t, valid)
+	assert.Equal(t, "InvalidSchedule", valid.Reason)
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "RestartFailed"))
+	ready := meta.FindStatusCondition(updated.Status.Conditions, "Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, "InvalidSchedule", ready.Reason)
+}
+
+func TestUnscheduleCancelsRun(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "missing-deployment"},
+			RetryPolicy: &v1alpha1.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     &metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}
+	reconciler := newTestReconciler(schedule)
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := client.ObjectKeyFromObject(schedule)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+	}()
+
+	// Wait for the first attempt, after which the run backs off for an hour.
+	require.Eventually(t, func() bool {
+		var updated v1alpha1.RestartSchedule
+		require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+		return updated.Status.LastExecution != nil && len(updated.Status.LastExecution.Attempts) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	reconciler.unschedule(key.String())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run was not canceled")
+	}
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.Equal(t, v1alpha1.ExecutionRunning, updated.Status.LastExecution.Result)
+	assert.Empty(t, updated.Status.History)
+}
+
+func TestRetrySettings(t *testing.T) {
+	attempts, _ := retrySettings(nil)
+	assert.Equal(t, int32(1), attempts)
+
+	attempts, backoff := retrySettings(&v1alpha1.RetryPolicy{})
+	assert.Equal(t, int32(defaultRetryAttempts), attempts)
+	assert.Equal(t, defaultBackoff, backoff)
+
+	attempts, backoff = retrySettings(&v1alpha1.RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     &metav1.Duration{Duration: time.Minute},
+	})
+	assert.Equal(t, int32(5), attempts)
+	assert.Equal(t, time.Minute, backoff)
+}
+
+func TestRunScheduledRestartStopsAtFailedStage(t *testing.T) {
+	pollFast(t)
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			Stages: []v1alpha1.RestartStage{
+				{Name: "cache", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "cache"}}},
+				{Name: "api", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "api"}}},
+				{Name: "workers", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "workers"}}},
+			},
+			RolloutTimeout: &metav1.Duration{Duration: 20 * time.Millisecond},
+		},
+	}
+	rolledOut := appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	cache := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default"},
+		Status:     rolledOut,
+	}
+	api := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Status:     appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	workers := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "default"},
+		Status:     rolledOut,
+	}
+	reconciler := newTestReconciler(schedule, cache, api, workers)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Result)
+	require.Len(t, execution.Stages, 2)
+	assert.Equal(t, "cache", execution.Stages[0].Name)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Stages[0].Result)
+	assert.Equal(t, "api", execution.Stages[1].Name)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Stages[1].Result)
+	assert.Contains(t, execution.Stages[1].Message, "rollout of Deployment api did not complete")
+
+	require.Len(t, updated.Status.Conditions, 1)
+	assert.Equal(t, "RolloutFailed", updated.Status.Conditions[0].Reason)
+
+	var untouched appsv1.Deployment
+	require.NoError(t, reconciler.Get(context.Background(),
+		types.NamespacedName{Name: "workers", Namespace: "default"}, &untouched))
+	assert.NotContains(t, untouched.Spec.Template.Annotations, restartedAtAnnotation)
+}
+
+func TestCanaryPlan(t *testing.T) {
+	targets := []v1alpha1.TargetRef{
+		{Kind: "Deployment", Name: "a"},
+		{Kind: "Deployment", Name: "b"},
+		{Kind: "Deployment", Name: "c"},
+		{Kind: "Deployment", Name: "d"},
+	}
+	count := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		name           string
+		targets        []v1alpha1.TargetRef
+		canary         v1alpha1.CanaryPolicy
+		canaryTargets  int
+		remaining      int
+		expectedStages int
+	}{
+		{
+			name:           "Defaults to a single canary",
+			targets:        targets,
+			canary:         v1alpha1.CanaryPolicy{},
+			canaryTargets:  1,
+			remaining:      3,
+			expectedStages: 2,
+		},
+		{
+			name:           "Explicit count",
+			targets:        targets,
+			canary:         v1alpha1.CanaryPolicy{Count: count(2)},
+			canaryTargets:  2,
+			remaining:      2,
+			expectedStages: 2,
+		},
+		{
+			name:           "Percentage rounds up",
+			targets:        targets,
+			canary:         v1alpha1.CanaryPolicy{Percentage: count(30)},
+			canaryTargets:  2,
+			remaining:      2,
+			expectedStages: 2,
+		},
+		{
+			name:           "Count covering every target",
+			targets:        targets,
+			canary:         v1alpha1.CanaryPolicy{Count: count(10)},
+			canaryTargets:  4,
+			expectedStages: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages := canaryPlan(tt.targets, &tt.canary)
+			require.Len(t, stages, tt.expectedStages)
+			assert.Equal(t, "canary", stages[0].name)
+			assert.Len(t, stages[0].targets, tt.canaryTargets)
+			assert.True(t, stages[0].waitForRollout)
+			assert.Equal(t, defaultSoakDuration, stages[0].soakDuration)
+			if tt.expectedStages > 1 {
+				assert.Equal(t, "remaining", stages[1].name)
+				assert.Len(t, stages[1].targets, tt.remaining)
+				assert.Zero(t, stages[1].soakDuration)
+			}
+		})
+	}
+}
+
+func TestRunScheduledRestartWithCanary(t *testing.T) {
+	pollFast(t)
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			Targets: []v1alpha1.TargetRef{
+				{Kind: "Deployment", Name: "eu"},
+				{Kind: "Deployment", Name: "us"},
+			},
+			Canary: &v1alpha1.CanaryPolicy{
+				SoakDuration: &metav1.Duration{Duration: 5 * time.Millisecond},
+			},
+		},
+	}
+	rolledOut := appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	eu := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "eu", Namespace: "default"}, Status: rolledOut}
+	us := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "us", Namespace: "default"}, Status: rolledOut}
+	reconciler := newTestReconciler(schedule, eu, us)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Result)
+	require.Len(t, execution.Stages, 2)
+	assert.Equal(t, "canary", execution.Stages[0].Name)
+	assert.Equal(t, "remaining", execution.Stages[1].Name)
+
+	for _, name := range []string{"eu", "us"} {
+		var deployment appsv1.Deployment
+		require.NoError(t, reconciler.Get(context.Background(),
+			types.NamespacedName{Name: name, Namespace: "default"}, &deployment))
+		assert.Contains(t, deployment.Spec.Template.Annotations, restartedAtAnnotation)
+	}
+}
+
+// recordedEvent is an event captured by eventCollector.
+type recordedEvent struct {
+	kind, name, eventType, reason, message string
+}
+
+// eventCollector is an EventRecorder that remembers which object every event
+// was recorded on.
+type eventCollector struct {
+	events []recordedEvent
+}
+
+func (c *eventCollector) Event(object runtime.Object, eventType, reason, message string) {
+	obj := object.(client.Object)
+	c.events = append(c.events, recordedEvent{
+		kind:      object.GetObjectKind().GroupVersionKind().Kind,
+		name:      obj.GetName(),
+		eventType: eventType,
+		reason:    reason,
+		message:   message,
+	})
+}
+
+func (c *eventCollector) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	c.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (c *eventCollector) AnnotatedEventf(object runtime.Object, _ map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	c.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+func (c *eventCollector) reasons(name string) []string {
+	var reasons []string
+	for _, event := range c.events {
+		if event.name == name {
+			reasons = append(reasons, event.reason)
+		}
+	}
+	return reasons
+}
+
+func TestRestartEventsOnScheduleAndTarget(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			Targets: []v1alpha1.TargetRef{
+				{Kind: "Deployment", Name: "api"},
+				{Kind: "Deployment", Name: "missing"},
+			},
+		},
+	}
+	api := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, api)
+	recorder := &eventCollector{}
+	reconciler.Recorder = recorder
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	assert.Equal(t, []string{"RestartTriggered", "TargetNotFound", "RestartFailed"}, recorder.reasons("test-schedule"))
+	// Only the target the failure is attributed to is reported as failed.
+	assert.Equal(t, []string{"RestartTriggered"}, recorder.reasons("api"))
+	assert.Empty(t, recorder.reasons("missing"))
+
+	for _, event := range recorder.events {
+		if event.name == "api" && event.reason == "RestartTriggered" {
+			assert.Equal(t, "Deployment", event.kind)
+			assert.Contains(t, event.message, "RestartSchedule default/test-schedule")
+		}
+	}
+
+	// Once the missing target exists, the run completes.
+	missing := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}}
+	require.NoError(t, reconciler.Create(context.Background(), missing))
+	recorder.events = nil
+
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	assert.Equal(t, []string{"RestartTriggered", "RestartTriggered", "RestartCompleted"}, recorder.reasons("test-schedule"))
+	assert.Equal(t, []string{"RestartTriggered", "RestartCompleted"}, recorder.reasons("api"))
+	assert.Equal(t, []string{"RestartTriggered", "RestartCompleted"}, recorder.reasons("missing"))
+}
+
+func TestRestartAnnotationsIdentifyExecution(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	api := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, api)
+	reconciler.Annotations = AnnotationKeys{
+		RestartedBy: "acme.io/restarted-by",
+		ExecutionID: "acme.io/restart-id",
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.NotEmpty(t, execution.ID)
+	assert.Equal(t, v1alpha1.ScheduledRestart, execution.Reason)
+
+	var deployment appsv1.Deployment
+	require.NoError(t, reconciler.Get(context.Background(),
+		types.NamespacedName{Name: "api", Namespace: "default"}, &deployment))
+	annotations := deployment.Spec.Template.Annotations
+	assert.Contains(t, annotations, restartedAtAnnotation)
+	assert.Equal(t, "default/test-schedule", annotations["acme.io/restarted-by"])
+	assert.Equal(t, "schedule", annotations[restartReasonAnnotation])
+	assert.Equal(t, execution.ID, annotations["acme.io/restart-id"])
+	assert.NotContains(t, annotations, restartedByAnnotation)
+}
+
+func TestSuspendedScheduleRunsOnlyManually(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			Suspend:   true,
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "test-deployment"},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+	}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.Nil(t, updated.Status.LastExecution)
+
+	reconciler.run(context.Background(), &v1alpha1.RestartSchedule{}, key, cronSchedule, v1alpha1.ManualRestart)
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ManualRestart, updated.Status.LastExecution.Reason)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+	assert.Equal(t, "The last restart succeeded", meta.FindStatusCondition(updated.Status.Conditions, "RestartFailed").Message)
+	assert.Nil(t, updated.Status.NextScheduledTime)
+	require.Len(t, updated.Status.History, 1)
+	assert.Equal(t, updated.Status.LastExecution.ID, updated.Status.History[0].ID)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.History[0].Result)
+}
+
+func TestTriggerAnnotationStartsManualRun(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-schedule",
+			Namespace:   "default",
+			Annotations: map[string]string{v1alpha1.TriggerAnnotation: "2025-03-01T12:00:00Z"},
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			Suspend:   true,
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "test-deployment"},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+	}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Empty(t, reconciler.scheduleIDs, "a suspended schedule must not be registered")
+
+	var updated v1alpha1.RestartSchedule
+	require.Eventually(t, func() bool {
+		require.NoError(t, reconciler.Get(ctx, key, &updated))
+		return updated.Status.LastExecution != nil && updated.Status.LastExecution.Result == v1alpha1.ExecutionSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, v1alpha1.ManualRestart, updated.Status.LastExecution.Reason)
+	assert.Equal(t, "2025-03-01T12:00:00Z", updated.Status.LastHandledTrigger)
+	assert.Nil(t, updated.Status.NextScheduledTime)
+	valid := meta.FindStatusCondition(updated.Status.Conditions, "Valid")
+	require.NotNil(t, valid)
+	assert.Equal(t, "Suspended", valid.Reason)
+	ready := meta.FindStatusCondition(updated.Status.Conditions, "Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, "Suspended", ready.Reason)
+
+	executionID := updated.Status.LastExecution.ID
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, reconciler.Get(ctx, key, &updated))
+	assert.Equal(t, executionID, updated.Status.LastExecution.ID, "a handled trigger must not start another run")
+}
+
+func TestRecordHistory(t *testing.T) {
+	status := &v1alpha1.RestartScheduleStatus{}
+	for i := range historyLimit + 2 {
+		recordHistory(status, &v1alpha1.RestartExecution{
+			ID:     fmt.Sprint(i),
+			Result: v1alpha1.ExecutionSucceeded,
+		})
+	}
+	require.Len(t, status.History, historyLimit)
+	assert.Equal(t, fmt.Sprint(historyLimit+1), status.History[0].ID)
+	assert.Equal(t, "2", status.History[historyLimit-1].ID)
+}
+
+func TestDryRunRestartsNothing(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			DryRun:   true,
+			Targets: []v1alpha1.TargetRef{
+				{Kind: "Deployment", Name: "api"},
+				{Kind: "Deployment", Name: "worker"},
+			},
+		},
+	}
+	api := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	worker := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, api, worker)
+	recorder := &eventCollector{}
+	reconciler.Recorder = recorder
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	for _, name := range []string{"api", "worker"} {
+		var deployment appsv1.Deployment
+		require.NoError(t, reconciler.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, &deployment))
+		assert.Empty(t, deployment.Spec.Template.Annotations, "a dry run must not patch %s", name)
+		assert.Empty(t, recorder.reasons(name))
+	}
+	assert.Equal(t, []string{"DryRunRestart", "DryRunRestart", "DryRunCompleted"}, recorder.reasons("test-schedule"))
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.True(t, updated.Status.LastExecution.DryRun)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+	assert.Equal(t, "Dry run: would have restarted Deployment default/api, Deployment default/worker",
+		updated.Status.LastExecution.Message)
+	assert.Nil(t, updated.Status.LastSuccessfulTime)
+	assert.Empty(t, updated.Status.Conditions)
+	require.Len(t, updated.Status.History, 1)
+	assert.True(t, updated.Status.History[0].DryRun)
+}
+
+func TestOperatorDryRun(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "missing"},
+		},
+	}
+	reconciler := newTestReconciler(schedule)
+	reconciler.DryRun = true
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.True(t, updated.Status.LastExecution.DryRun)
+	assert.Equal(t, v1alpha1.ExecutionFailed, updated.Status.LastExecution.Result)
+	assert.Empty(t, updated.Status.Conditions, "a failed dry run must not set RestartFailed")
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// scheduleFinalizer keeps a deleted schedule around until its cron entry has
+// been removed and, with cleanupOnDelete, its annotations have been removed
+// from the targets.
+const scheduleFinalizer = "restart-operator.k8s/finalizer"
+
+type cancelableContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// runContext returns the context that runs of the schedule registered under
+// key are canceled through.
+func (r *RestartScheduleReconciler) runContext(key string) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rc, ok := r.runContexts[key]; ok {
+		return rc.ctx
+	}
+	if r.runContexts == nil {
+		r.runContexts = make(map[string]cancelableContext)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.runContexts[key] = cancelableContext{ctx: ctx, cancel: cancel}
+	return ctx
+}
+
+// register replaces the cron entry registered under key with one running job
+// on cronSchedule, or just removes it if job is nil. Runs that are still
+// going on are left alone.
+func (r *RestartScheduleReconciler) register(key string, cronSchedule cron.Schedule, job cron.Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, exists := r.scheduleIDs[key]; exists {
+		r.cron.Remove(id)
+		delete(r.scheduleIDs, key)
+	}
+	if job != nil {
+		r.scheduleIDs[key] = r.cron.Schedule(cronSchedule, job)
+	}
+}
+
+// unschedule removes the cron entry registered under key, if any, and
+// cancels the runs of the schedule that are still going on.
+func (r *RestartScheduleReconciler) unschedule(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rc, ok := r.runContexts[key]; ok {
+		rc.cancel()
+		delete(r.runContexts, key)
+	}
+	id, exists := r.scheduleIDs[key]
+	if exists {
+		r.cron.Remove(id)
+		delete(r.scheduleIDs, key)
+	}
+	return exists
+}
+
+// finalize unregisters a schedule that is being deleted, cleans up its
+// targets if requested and then releases the finalizer.
+func (r *RestartScheduleReconciler) finalize(ctx context.Context, schedule scheduleObject, key string) error {
+	r.unschedule(key)
+	if !controllerutil.ContainsFinalizer(schedule, scheduleFinalizer) {
+		return nil
+	}
+
+	if schedule.GetScheduleSpec().CleanupOnDelete {
+		if err := r.cleanupTargets(ctx, schedule); err != nil {
+			return err
+		}
+	}
+
+	base := copySchedule(schedule)
+	controllerutil.RemoveFinalizer(schedule, scheduleFinalizer)
+	return r.patchMetadata(ctx, schedule, base)
+}
+
+// cleanupTargets removes the restart annotations from the pod templates of
+// the targets last restarted by the schedule. Targets that no longer exist
+// are skipped. Dry-run schedules only send the patches as dry runs.
+func (r *RestartScheduleReconciler) cleanupTargets(ctx context.Context, schedule scheduleObject) error {
+	resolved := copySchedule(schedule)
+	if err := r.resolveTargets(ctx, resolved); err != nil {
+		var skipped *skipError
+		if errors.As(err, &skipped) {
+			return nil
+		}
+		return err
+	}
+
+	dryRun := r.DryRun || schedule.GetScheduleSpec().DryRun
+	for _, ref := range scheduleTargets(resolved.GetScheduleSpec()) {
+		namespace := targetNamespace(ref, schedule.GetNamespace())
+		err := r.removeRestartAnnotations(ctx, ref, namespace, scheduleName(schedule), dryRun)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to clean up %s %s/%s: %w", ref.Kind, namespace, ref.Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureMetadata adds the finalizer to the schedule and, with
+// deleteWithTarget, makes its existing targets in the same namespace its
+// owners. Owner references to the targets are dropped again when
+// deleteWithTarget is turned off.
+func (r *RestartScheduleReconciler) ensureMetadata(ctx context.Context, schedule scheduleObject) error {
+	base := copySchedule(schedule)
+	controllerutil.AddFinalizer(schedule, scheduleFinalizer)
+
+	if schedule.GetNamespace() != "" {
+		if err := r.updateOwnerReferences(ctx, schedule); err != nil {
+			return err
+		}
+	}
+
+	if slices.Equal(base.GetFinalizers(), schedule.GetFinalizers()) &&
+		slices.EqualFunc(base.GetOwnerReferences(), schedule.GetOwnerReferences(), ownerReferenceEqual) {
+		return nil
+	}
+	return r.patchMetadata(ctx, schedule, base)
+}
+
+// updateOwnerReferences adds or removes owner references to the targets of
+// the schedule in its own namespace, following deleteWithTarget. Controller
+// references, such as those of schedules generated from workload
+// annotations, are left alone.
+func (r *RestartScheduleReconciler) updateOwnerReferences(ctx context.Context, schedule scheduleObject) error {
+	spec := schedule.GetScheduleSpec()
+	for _, ref := range scheduleTargets(spec) {
+		if targetNamespace(ref, schedule.GetNamespace()) != schedule.GetNamespace() {
+			continue
+		}
+		gvk, _, err := r.targetKinds().Lookup(ref)
+		if err != nil {
+			continue
+		}
+
+		if !spec.DeleteWithTarget {
+			schedule.SetOwnerReferences(slices.DeleteFunc(schedule.GetOwnerReferences(), func(owner metav1.OwnerReference) bool {
+				return owner.APIVersion == gvk.GroupVersion().String() && owner.Kind == gvk.Kind && owner.Name == ref.Name &&
+					!ptr.Deref(owner.Controller, false)
+			}))
+			continue
+		}
+
+		target, err := r.getTarget(ctx, ref, schedule.GetNamespace())
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(schedule.GetOwnerReferences(), func(owner metav1.OwnerReference) bool {
+			return owner.UID == target.GetUID()
+		}) {
+			continue
+		}
+		if err := controllerutil.SetOwnerReference(target, schedule, r.Scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ownerReferenceEqual(a, b metav1.OwnerReference) bool {
+	return a.APIVersion == b.APIVersion && a.Kind == b.Kind && a.Name == b.Name && a.UID == b.UID
+}
+
+// patchMetadata sends the difference between base and schedule as a merge
+// patch. The resourceVersion is included, so finalizers and owner references
+// changed concurrently are not lost; on a conflict the reconcile is retried.
+func (r *RestartScheduleReconciler) patchMetadata(ctx context.Context, schedule, base scheduleObject) error {
+	return r.Patch(ctx, schedule,
+		client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}),
+		client.FieldOwner(fieldManager))
+}
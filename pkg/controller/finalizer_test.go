@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func annotatedDeployment(name, restartedBy string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					restartedAtAnnotation: "2025-01-01T03:00:00Z",
+					restartedByAnnotation: restartedBy,
+					"team":                "payments",
+				}},
+			},
+		},
+	}
+}
+
+func TestReconcileAddsFinalizerAndOwnerReference(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:         "0 3 * * *",
+			TargetRef:        &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+			DeleteWithTarget: true,
+		},
+	}
+	reconciler := newTestReconciler(schedule, annotatedDeployment("api", "default/test-schedule"))
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.Equal(t, []string{scheduleFinalizer}, updated.Finalizers)
+	require.Len(t, updated.OwnerReferences, 1)
+	assert.Equal(t, "Deployment", updated.OwnerReferences[0].Kind)
+	assert.Equal(t, "api", updated.OwnerReferences[0].Name)
+	assert.Equal(t, types.UID("api-uid"), updated.OwnerReferences[0].UID)
+
+	updated.Spec.DeleteWithTarget = false
+	require.NoError(t, reconciler.Update(context.Background(), &updated))
+	_, err = reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	assert.Empty(t, updated.OwnerReferences)
+}
+
+func TestDeletionCleansUpTargets(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 3 * * *",
+			Targets: []v1alpha1.TargetRef{
+				{Kind: "Deployment", Name: "api"},
+				{Kind: "Deployment", Name: "worker"},
+				{Kind: "Deployment", Name: "missing"},
+			},
+			CleanupOnDelete: true,
+		},
+	}
+	reconciler := newTestReconciler(schedule,
+		annotatedDeployment("api", "default/test-schedule"),
+		annotatedDeployment("worker", "default/other-schedule"))
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Len(t, reconciler.scheduleIDs, 1)
+
+	require.NoError(t, reconciler.Get(ctx, key, schedule))
+	require.NoError(t, reconciler.Delete(ctx, schedule))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	assert.Empty(t, reconciler.scheduleIDs)
+	err = reconciler.Get(ctx, key, &v1alpha1.RestartSchedule{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	var api appsv1.Deployment
+	require.NoError(t, reconciler.Get(ctx, types.NamespacedName{Name: "api", Namespace: "default"}, &api))
+	assert.Equal(t, map[string]string{"team": "payments"}, api.Spec.Template.Annotations)
+
+	var worker appsv1.Deployment
+	require.NoError(t, reconciler.Get(ctx, types.NamespacedName{Name: "worker", Namespace: "default"}, &worker))
+	assert.Equal(t, "default/other-schedule", worker.Spec.Template.Annotations[restartedByAnnotation])
+}
+
+func TestDryRunDeletionLeavesTargets(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:        "0 3 * * *",
+			TargetRef:       &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+			CleanupOnDelete: true,
+			DryRun:          true,
+		},
+	}
+	reconciler := newTestReconciler(schedule, annotatedDeployment("api", "default/test-schedule"))
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	require.NoError(t, reconciler.Get(ctx, key, schedule))
+	require.NoError(t, reconciler.Delete(ctx, schedule))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	err = reconciler.Get(ctx, key, &v1alpha1.RestartSchedule{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	var api appsv1.Deployment
+	require.NoError(t, reconciler.Get(ctx, types.NamespacedName{Name: "api", Namespace: "default"}, &api))
+	assert.Equal(t, "default/test-schedule", api.Spec.Template.Annotations[restartedByAnnotation])
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+)
+
+const prometheusQueryTimeout = 30 * time.Second
+
+// prometheusResponse is the part of the Prometheus HTTP API response to an
+// instant query that is needed to evaluate a health gate.
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// checkHealthGate evaluates the Prometheus query of the gate once and returns
+// an error describing why the gate is closed, if it is.
+func checkHealthGate(ctx context.Context, gate *v1alpha1.PrometheusHealthGate) error {
+	threshold, err := strconv.ParseFloat(gate.Threshold, 64)
+	if err != nil {
+		return fmt.Errorf("invalid health gate threshold %q: %w", gate.Threshold, err)
+	}
+
+	values, err := queryPrometheus(ctx, gate.Address, gate.Query)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("health gate query %q returned no samples", gate.Query)
+	}
+
+	operator := gate.Operator
+	if operator == "" {
+		operator = v1alpha1.LessThan
+	}
+	for _, value := range values {
+		passed, err := compare(value, operator, threshold)
+		if err != nil {
+			return err
+		}
+		if !passed {
+			return fmt.Errorf("health gate query %q returned %g, expected %s %s", gate.Query, value, operator, gate.Threshold)
+		}
+	}
+	return nil
+}
+
+// soakHealthGate evaluates the gate repeatedly for the soak duration of the
+// gate and fails as soon as it closes.
+func soakHealthGate(ctx context.Context, gate *v1alpha1.PrometheusHealthGate) error {
+	duration := defaultSoakDuration
+	if gate.SoakDuration != nil {
+		duration = gate.SoakDuration.Duration
+	}
+
+	deadline := time.Now().Add(duration)
+	for {
+		if err := checkHealthGate(ctx, gate); err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(rolloutPollInterval, remaining)):
+		}
+	}
+}
+
+// queryPrometheus runs an instant query and returns the values of all samples
+// of a vector result, or the value of a scalar result.
+func queryPrometheus(ctx context.Context, address, query string) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, prometheusQueryTimeout)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(address, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Prometheus response: %w", err)
+	}
+	var response prometheusResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("prometheus responded with %s: %w", resp.Status, err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s: %s", response.ErrorType, response.Error)
+	}
+
+	switch response.Data.ResultType {
+	case "vector":
+		var samples []struct {
+			Value [2]interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(response.Data.Result, &samples); err != nil {
+			return nil, err
+		}
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			value, err := sampleValue(sample.Value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case "scalar":
+		var sample [2]interface{}
+		if err := json.Unmarshal(response.Data.Result, &sample); err != nil {
+			return nil, err
+		}
+		value, err := sampleValue(sample)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Prometheus result type %q, the query must return a vector or scalar", response.Data.ResultType)
+	}
+}
+
+// sampleValue parses a [timestamp, "value"] pair as returned by Prometheus.
+func sampleValue(sample [2]interface{}) (float64, error) {
+	raw, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected Prometheus sample value %v", sample[1])
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+func compare(value float64, operator v1alpha1.ComparisonOperator, threshold float64) (bool, error) {
+	switch operator {
+	case v1alpha1.LessThan:
+		return value < threshold, nil
+	case v1alpha1.LessThanOrEqual:
+		return value <= threshold, nil
+	case v1alpha1.GreaterThan:
+		return value > threshold, nil
+	case v1alpha1.GreaterThanOrEqual:
+		return value >= threshold, nil
+	default:
+		return false, fmt.Errorf("unsupported health gate operator %q", operator)
+	}
+}
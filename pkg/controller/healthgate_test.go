@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakePrometheus answers instant queries with a single sample. The value can be
+// changed by the test, and the number of queries served is counted.
+type fakePrometheus struct {
+	value   atomic.Value
+	queries atomic.Int32
+	// unhealthyAfter switches the value to 1 once that many queries were served.
+	unhealthyAfter int32
+}
+
+func (p *fakePrometheus) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/api/v1/query" || req.URL.Query().Get("query") == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"status":"error","errorType":"bad_data","error":"missing query"}`))
+		return
+	}
+
+	n := p.queries.Add(1)
+	value := p.value.Load().(string)
+	if p.unhealthyAfter > 0 && n > p.unhealthyAfter {
+		value = "1"
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(rw, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,%q]}]}}`, value)
+}
+
+func newFakePrometheus(value string) *fakePrometheus {
+	p := &fakePrometheus{}
+	p.value.Store(value)
+	return p
+}
+
+func healthGateTestSchedule(address string) *v1alpha1.RestartSchedule {
+	schedule := newTestSchedule()
+	schedule.Spec.HealthGate = &v1alpha1.HealthGate{
+		Prometheus: &v1alpha1.PrometheusHealthGate{
+			Address:      address,
+			Query:        `sum(rate(http_requests_total{code=~"5.."}[5m]))`,
+			Threshold:    "0.5",
+			SoakDuration: &metav1.Duration{Duration: 20 * time.Millisecond},
+		},
+	}
+	return schedule
+}
+
+func runHealthGateSchedule(t *testing.T, address string) (*RestartScheduleReconciler, *v1alpha1.RestartSchedule) {
+	schedule := healthGateTestSchedule(address)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"},
+		Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	return reconciler, &updated
+}
+
+func deploymentRestarted(t *testing.T, reconciler *RestartScheduleReconciler) bool {
+	var deployment appsv1.Deployment
+	require.NoError(t, reconciler.Get(context.Background(),
+		types.NamespacedName{Name: "test-deployment", Namespace: "default"}, &deployment))
+	_, restarted := deployment.Spec.Template.Annotations[restartedAtAnnotation]
+	return restarted
+}
+
+func TestHealthGateClosedSkipsRestart(t *testing.T) {
+	pollFast(t)
+
+	prometheus := newFakePrometheus("2")
+	server := httptest.NewServer(prometheus)
+	defer server.Close()
+
+	reconciler, updated := runHealthGateSchedule(t, server.URL)
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, execution.Result)
+	assert.Contains(t, execution.Message, "returned 2, expected LessThan 0.5")
+	assert.False(t, deploymentRestarted(t, reconciler))
+	assert.Equal(t, int32(1), prometheus.queries.Load())
+}
+
+func TestHealthGateSoakAfterRestart(t *testing.T) {
+	pollFast(t)
+
+	prometheus := newFakePrometheus("0")
+	server := httptest.NewServer(prometheus)
+	defer server.Close()
+
+	reconciler, updated := runHealthGateSchedule(t, server.URL)
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Result)
+	assert.True(t, deploymentRestarted(t, reconciler))
+	assert.Greater(t, prometheus.queries.Load(), int32(2))
+}
+
+func TestHealthGateRegressionFailsRestart(t *testing.T) {
+	pollFast(t)
+
+	prometheus := newFakePrometheus("0")
+	prometheus.unhealthyAfter = 2
+	server := httptest.NewServer(prometheus)
+	defer server.Close()
+
+	reconciler, updated := runHealthGateSchedule(t, server.URL)
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Result)
+	assert.True(t, deploymentRestarted(t, reconciler))
+
+	require.Len(t, updated.Status.Conditions, 1)
+	assert.Equal(t, "RestartFailed", updated.Status.Conditions[0].Type)
+	assert.Equal(t, metav1.ConditionTrue, updated.Status.Conditions[0].Status)
+	assert.Equal(t, "HealthGateFailed", updated.Status.Conditions[0].Reason)
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		operator v1alpha1.ComparisonOperator
+		value    float64
+		passed   bool
+	}{
+		{v1alpha1.LessThan, 0.4, true},
+		{v1alpha1.LessThan, 0.5, false},
+		{v1alpha1.LessThanOrEqual, 0.5, true},
+		{v1alpha1.GreaterThan, 0.5, false},
+		{v1alpha1.GreaterThan, 0.6, true},
+		{v1alpha1.GreaterThanOrEqual, 0.5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%g %s 0.5", tt.value, tt.operator), func(t *testing.T) {
+			passed, err := compare(tt.value, tt.operator, 0.5)
+			require.NoError(t, err)
+			assert.Equal(t, tt.passed, passed)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	scheduleLabel = "restart-operator.k8s/schedule"
+	hookLabel     = "restart-operator.k8s/hook"
+
+	defaultHookTimeout = 10 * time.Minute
+
+	// defaultHookJobTTL is how long finished hook Jobs are kept when their
+	// template does not set ttlSecondsAfterFinished.
+	defaultHookJobTTL = 24 * time.Hour
+)
+
+// runHook creates a Job from the hook template, owned by the schedule, and
+// waits for it to finish. The outcome is appended to the hooks of the
+// execution. Finished Jobs are removed after their TTL, which defaults to
+// defaultHookJobTTL.
+func (r *RestartScheduleReconciler) runHook(
+	ctx context.Context,
+	schedule scheduleObject,
+	phase v1alpha1.HookPhase,
+	template *batchv1.JobTemplateSpec,
+	execution *v1alpha1.RestartExecution,
+) error {
+	job := &batchv1.Job{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	job.Name = hookJobName(schedule.GetName(), phase, execution.StartTime.Time)
+	job.GenerateName = ""
+	job.Namespace = schedule.GetNamespace()
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[scheduleLabel] = schedule.GetName()
+	job.Labels[hookLabel] = strings.ToLower(string(phase))
+	if job.Spec.Template.Spec.RestartPolicy == "" {
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		job.Spec.TTLSecondsAfterFinished = ptr.To(int32(defaultHookJobTTL.Seconds()))
+	}
+	if err := controllerutil.SetControllerReference(schedule, job, r.Scheme); err != nil {
+		return err
+	}
+
+	logger := r.Log.WithValues(
+		"restartschedule", client.ObjectKeyFromObject(schedule),
+		"hook", phase,
+		"job", job.Name,
+	)
+
+	execution.Hooks = append(execution.Hooks, v1alpha1.HookStatus{
+		Phase:     phase,
+		JobName:   job.Name,
+		Result:    v1alpha1.ExecutionRunning,
+		StartTime: metav1.Now(),
+	})
+	hookStatus := &execution.Hooks[len(execution.Hooks)-1]
+
+	err := r.authorizeHookServiceAccount(ctx, job)
+	if err == nil {
+		logger.Info("Running hook Job")
+		err = r.Create(ctx, job, client.FieldOwner(fieldManager))
+	}
+	if err == nil {
+		r.recordExecution(ctx, schedule, execution)
+		err = r.waitForJob(ctx, job, hookTimeout(schedule.GetScheduleSpec().Hooks))
+	}
+
+	now := metav1.Now()
+	hookStatus.CompletionTime = &now
+	if err != nil {
+		logger.Error(err, "Hook Job failed")
+		hookStatus.Result = v1alpha1.ExecutionFailed
+		hookStatus.Message = err.Error()
+		return fmt.Errorf("%s hook Job %s failed: %w", phase, job.Name, err)
+	}
+
+	logger.Info("Hook Job completed")
+	hookStatus.Result = v1alpha1.ExecutionSucceeded
+	return nil
+}
+
+// authorizeHookServiceAccount refuses hook Jobs that run as a ServiceAccount
+// other than the default one, unless that ServiceAccount carries the
+// HookServiceAccountLabel set to "true".
+func (r *RestartScheduleReconciler) authorizeHookServiceAccount(ctx context.Context, job *batchv1.Job) error {
+	name := job.Spec.Template.Spec.ServiceAccountName
+	if name == "" {
+		name = job.Spec.Template.Spec.DeprecatedServiceAccount
+	}
+	if name == "" || name == "default" {
+		return nil
+	}
+
+	var serviceAccount corev1.ServiceAccount
+	key := types.NamespacedName{Name: name, Namespace: job.Namespace}
+	if err := r.Get(ctx, key, &serviceAccount); err != nil {
+		return fmt.Errorf("failed to get hook ServiceAccount %s: %w", key, err)
+	}
+	if serviceAccount.Labels[v1alpha1.HookServiceAccountLabel] != "true" {
+		return fmt.Errorf("hook ServiceAccount %s is not labeled %s=true", key, v1alpha1.HookServiceAccountLabel)
+	}
+	return nil
+}
+
+// waitForJob waits for the Job to complete or fail. A Job that has not
+// finished within the timeout, or when the run is canceled, is deleted along
+// with its pods.
+func (r *RestartScheduleReconciler) waitForJob(ctx context.Context, job *batchv1.Job, timeout time.Duration) error {
+	var failure error
+	err := wait.PollUntilContextTimeout(ctx, rolloutPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		var current batchv1.Job
+		if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &current); err != nil {
+			return false, err
+		}
+		for _, condition := range current.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				return true, nil
+			case batchv1.JobFailed:
+				failure = fmt.Errorf("%s: %s", condition.Reason, condition.Message)
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		deleteErr := r.Delete(context.WithoutCancel(ctx), job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if deleteErr != nil && !apierrors.IsNotFound(deleteErr) {
+			r.Log.Error(deleteErr, "Failed to delete unfinished hook Job", "job", client.ObjectKeyFromObject(job))
+		}
+		return err
+	}
+	return failure
+}
+
+// hookJobName derives a name that is unique per execution and short enough to
+// be used as a label value on the Job's pods.
+func hookJobName(scheduleName string, phase v1alpha1.HookPhase, start time.Time) string {
+	suffix := fmt.Sprintf("-%s-%d", strings.ToLower(strings.TrimSuffix(string(phase), "Restart")), start.Unix())
+	maxPrefix := 63 - len(suffix)
+	if len(scheduleName) > maxPrefix {
+		scheduleName = strings.TrimRight(scheduleName[:maxPrefix], "-.")
+	}
+	return scheduleName + suffix
+}
+
+func hookTimeout(hooks *v1alpha1.RestartHooks) time.Duration {
+	if hooks == nil || hooks.Timeout == nil {
+		return defaultHookTimeout
+	}
+	return hooks.Timeout.Duration
+}
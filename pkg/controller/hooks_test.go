@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// finishJobs makes the fake client finish every Job as soon as it is
+// created, failing the ones whose name contains failJob.
+func finishJobs(failJob string) interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if job, ok := obj.(*batchv1.Job); ok {
+				conditionType := batchv1.JobComplete
+				if failJob != "" && strings.Contains(job.Name, failJob) {
+					conditionType = batchv1.JobFailed
+				}
+				job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+					Type:    conditionType,
+					Status:  corev1.ConditionTrue,
+					Reason:  "BackoffLimitExceeded",
+					Message: "Job has reached the specified backoff limit",
+				})
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+}
+
+func hookTestSchedule() *v1alpha1.RestartSchedule {
+	template := &batchv1.JobTemplateSpec{
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "hook", Image: "busybox"}},
+				},
+			},
+		},
+	}
+	schedule := newTestSchedule()
+	schedule.UID = "test-uid"
+	schedule.Spec.Hooks = &v1alpha1.RestartHooks{
+		PreRestart:  template,
+		PostRestart: template.DeepCopy(),
+	}
+	return schedule
+}
+
+func TestHooksRunAroundRestart(t *testing.T) {
+	pollFast(t)
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newInterceptedTestReconciler(finishJobs(""), hookTestSchedule(), deployment)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Result)
+	require.Len(t, execution.Hooks, 2)
+	assert.Equal(t, v1alpha1.PreRestartHook, execution.Hooks[0].Phase)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Hooks[0].Result)
+	assert.Equal(t, v1alpha1.PostRestartHook, execution.Hooks[1].Phase)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, execution.Hooks[1].Result)
+
+	var jobs batchv1.JobList
+	require.NoError(t, reconciler.List(context.Background(), &jobs, client.InNamespace("default")))
+	require.Len(t, jobs.Items, 2)
+	for _, job := range jobs.Items {
+		assert.Equal(t, "test-schedule", job.Labels[scheduleLabel])
+		require.Len(t, job.OwnerReferences, 1)
+		assert.Equal(t, "RestartSchedule", job.OwnerReferences[0].Kind)
+		assert.Equal(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+		assert.Equal(t, ptr.To(int32(86400)), job.Spec.TTLSecondsAfterFinished)
+	}
+}
+
+func TestTimedOutHookJobIsDeleted(t *testing.T) {
+	pollFast(t)
+
+	schedule := hookTestSchedule()
+	schedule.Spec.Hooks.Timeout = &metav1.Duration{Duration: 20 * time.Millisecond}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Result)
+	require.Len(t, execution.Hooks, 1)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Hooks[0].Result)
+
+	var jobs batchv1.JobList
+	require.NoError(t, reconciler.List(context.Background(), &jobs, client.InNamespace("default")))
+	assert.Empty(t, jobs.Items)
+}
+
+func TestFailingPreRestartHookAbortsRestart(t *testing.T) {
+	pollFast(t)
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newInterceptedTestReconciler(finishJobs("-pre-"), hookTestSchedule(), deployment)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Result)
+	require.Len(t, execution.Hooks, 1)
+	assert.Equal(t, v1alpha1.ExecutionFailed, execution.Hooks[0].Result)
+	assert.Contains(t, execution.Hooks[0].Message, "BackoffLimitExceeded")
+	assert.Empty(t, execution.Attempts)
+
+	require.Len(t, updated.Status.Conditions, 1)
+	assert.Equal(t, "PreRestartHookFailed", updated.Status.Conditions[0].Reason)
+
+	var untouched appsv1.Deployment
+	require.NoError(t, reconciler.Get(context.Background(),
+		types.NamespacedName{Name: "test-deployment", Namespace: "default"}, &untouched))
+	assert.NotContains(t, untouched.Spec.Template.Annotations, restartedAtAnnotation)
+}
+
+func TestHookServiceAccountMustBeLabeled(t *testing.T) {
+	pollFast(t)
+
+	schedule := hookTestSchedule()
+	schedule.Spec.Hooks.PostRestart = nil
+	schedule.Spec.Hooks.PreRestart.Spec.Template.Spec.ServiceAccountName = "cluster-admin"
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin", Namespace: "default"}}
+	reconciler := newInterceptedTestReconciler(finishJobs(""), schedule, deployment, serviceAccount)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	ctx := context.Background()
+
+	reconciler.runScheduledRestart(ctx, key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(ctx, key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionFailed, updated.Status.LastExecution.Result)
+	require.Len(t, updated.Status.LastExecution.Hooks, 1)
+	assert.Contains(t, updated.Status.LastExecution.Hooks[0].Message, "is not labeled "+v1alpha1.HookServiceAccountLabel)
+	var jobs batchv1.JobList
+	require.NoError(t, reconciler.List(ctx, &jobs))
+	assert.Empty(t, jobs.Items, "no Job may run as an unlabeled ServiceAccount")
+
+	// Once the ServiceAccount is allowed for hooks, the hook runs.
+	serviceAccount.Labels = map[string]string{v1alpha1.HookServiceAccountLabel: "true"}
+	require.NoError(t, reconciler.Update(ctx, serviceAccount))
+	reconciler.runScheduledRestart(ctx, key, cronSchedule)
+
+	require.NoError(t, reconciler.Get(ctx, key, &updated))
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+	require.NoError(t, reconciler.List(ctx, &jobs))
+	require.Len(t, jobs.Items, 1)
+	assert.Equal(t, "cluster-admin", jobs.Items[0].Spec.Template.Spec.ServiceAccountName)
+}
+
+func TestHookJobName(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	assert.Equal(t, "nightly-pre-1700000000", hookJobName("nightly", v1alpha1.PreRestartHook, start))
+	assert.Equal(t, "nightly-post-1700000000", hookJobName("nightly", v1alpha1.PostRestartHook, start))
+
+	long := hookJobName(strings.Repeat("a", 80), v1alpha1.PostRestartHook, start)
+	assert.LessOrEqual(t, len(long), 63)
+	assert.True(t, strings.HasSuffix(long, "-post-1700000000"))
+}
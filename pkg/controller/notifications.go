@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	notificationTimeout = 10 * time.Second
+
+	defaultNotificationTemplate = `{{ if .Execution.DryRun }}[dry run] {{ end }}` +
+		`Scheduled restart of {{ .Schedule.Namespace }}/{{ .Schedule.Name }} {{ lower .Event }}` +
+		`{{ with .Execution.Message }}: {{ . }}{{ end }}`
+)
+
+var notificationFuncs = template.FuncMap{
+	"lower": func(v interface{}) string { return strings.ToLower(fmt.Sprint(v)) },
+	"upper": func(v interface{}) string { return strings.ToUpper(fmt.Sprint(v)) },
+}
+
+// notificationData is what notifier templates are executed with.
+type notificationData struct {
+	Event     v1alpha1.NotificationEvent
+	Schedule  scheduleObject
+	Execution *v1alpha1.RestartExecution
+}
+
+// notify sends the event to every RestartNotifier in the namespace of the
+// schedule that selects it. Notifiers are namespaced, so cluster-scoped
+// schedules send none. Notifications never affect the restart, so failures
+// are only logged.
+func (r *RestartScheduleReconciler) notify(
+	ctx context.Context,
+	schedule scheduleObject,
+	event v1alpha1.NotificationEvent,
+	execution *v1alpha1.RestartExecution,
+) {
+	if schedule.GetNamespace() == "" {
+		return
+	}
+	logger := r.Log.WithValues(
+		"restartschedule", client.ObjectKeyFromObject(schedule),
+		"event", event,
+	)
+
+	var notifiers v1alpha1.RestartNotifierList
+	if err := r.List(ctx, &notifiers, client.InNamespace(schedule.GetNamespace())); err != nil {
+		logger.Error(err, "Failed to list RestartNotifiers")
+		return
+	}
+
+	data := notificationData{Event: event, Schedule: schedule, Execution: execution}
+	for i := range notifiers.Items {
+		notifier := &notifiers.Items[i]
+		selected, err := notifierSelects(notifier, schedule, event)
+		if err != nil {
+			logger.Error(err, "Invalid RestartNotifier selector", "notifier", notifier.Name)
+			continue
+		}
+		if !selected {
+			continue
+		}
+		if err := r.sendNotification(ctx, notifier, data); err != nil {
+			logger.Error(err, "Failed to send notification", "notifier", notifier.Name)
+		}
+	}
+}
+
+func notifierSelects(notifier *v1alpha1.RestartNotifier, schedule scheduleObject, event v1alpha1.NotificationEvent) (bool, error) {
+	if len(notifier.Spec.Events) > 0 && !slices.Contains(notifier.Spec.Events, event) {
+		return false, nil
+	}
+	if notifier.Spec.Selector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(notifier.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(schedule.GetLabels())), nil
+}
+
+func (r *RestartScheduleReconciler) sendNotification(ctx context.Context, notifier *v1alpha1.RestartNotifier, data notificationData) error {
+	text, err := renderNotification(notifier.Spec.Template, data)
+	if err != nil {
+		return err
+	}
+	body, err := notificationBody(notifier.Spec.Type, text)
+	if err != nil {
+		return err
+	}
+
+	var secret corev1.Secret
+	ref := notifier.Spec.URLFrom
+	key := types.NamespacedName{Name: ref.Name, Namespace: notifier.Namespace}
+	if err := r.Get(ctx, key, &secret); err != nil {
+		return fmt.Errorf("failed to get webhook URL Secret %s: %w", key, err)
+	}
+	if secret.Labels[v1alpha1.WebhookCredentialLabel] != "true" {
+		return fmt.Errorf("webhook URL Secret %s is not labeled %s=true", key, v1alpha1.WebhookCredentialLabel)
+	}
+	url, ok := secret.Data[ref.Key]
+	if !ok {
+		return fmt.Errorf("key %q not found in Secret %s", ref.Key, key)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(string(url)), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("incoming webhook responded with %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func renderNotification(text string, data notificationData) (string, error) {
+	if text == "" {
+		text = defaultNotificationTemplate
+	}
+	tmpl, err := template.New("notification").Funcs(notificationFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid notification template: %w", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render notification template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// notificationBody wraps the text in the payload expected by the incoming
+// webhook: a plain Slack message or a Teams MessageCard.
+func notificationBody(notifierType v1alpha1.NotifierType, text string) ([]byte, error) {
+	switch notifierType {
+	case "", v1alpha1.SlackNotifier:
+		return json.Marshal(map[string]string{"text": text})
+	case v1alpha1.TeamsNotifier:
+		return json.Marshal(map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  text,
+			"text":     text,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported notifier type %q", notifierType)
+	}
+}
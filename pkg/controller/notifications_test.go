@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// incomingWebhook records the JSON messages posted to it.
+type incomingWebhook struct {
+	mu       sync.Mutex
+	messages []map[string]string
+}
+
+func (w *incomingWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var message map[string]string
+	_ = json.NewDecoder(req.Body).Decode(&message)
+
+	w.mu.Lock()
+	w.messages = append(w.messages, message)
+	w.mu.Unlock()
+}
+
+func TestNotifiersReceiveRestartEvents(t *testing.T) {
+	slack := &incomingWebhook{}
+	slackServer := httptest.NewServer(slack)
+	defer slackServer.Close()
+
+	teams := &incomingWebhook{}
+	teamsServer := httptest.NewServer(teams)
+	defer teamsServer.Close()
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+			Labels:    map[string]string{"team": "payments"},
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 * * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "test-deployment"},
+		},
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	slackSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "slack-webhook",
+			Namespace: "default",
+			Labels:    map[string]string{v1alpha1.WebhookCredentialLabel: "true"},
+		},
+		Data: map[string][]byte{"url": []byte(slackServer.URL)},
+	}
+	teamsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "teams-webhook",
+			Namespace: "default",
+			Labels:    map[string]string{v1alpha1.WebhookCredentialLabel: "true"},
+		},
+		Data: map[string][]byte{"url": []byte(teamsServer.URL + "\n")},
+	}
+
+	notifiers := []*v1alpha1.RestartNotifier{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "payments-slack", Namespace: "default"},
+			Spec: v1alpha1.RestartNotifierSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+				URLFrom: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "slack-webhook"},
+					Key:                  "url",
+				},
+				Template: "{{ .Schedule.Name }} {{ upper .Event }} {{ .Execution.Result }}",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "teams-outcome", Namespace: "default"},
+			Spec: v1alpha1.RestartNotifierSpec{
+				Type:   v1alpha1.TeamsNotifier,
+				Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationSucceeded, v1alpha1.NotificationFailed},
+				URLFrom: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "teams-webhook"},
+					Key:                  "url",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-team", Namespace: "default"},
+			Spec: v1alpha1.RestartNotifierSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "search"}},
+				URLFrom: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "slack-webhook"},
+					Key:                  "url",
+				},
+			},
+		},
+	}
+
+	reconciler := newTestReconciler(schedule, deployment, slackSecret, teamsSecret,
+		notifiers[0], notifiers[1], notifiers[2])
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	require.Len(t, slack.messages, 2)
+	assert.Equal(t, "test-schedule STARTED Running", slack.messages[0]["text"])
+	assert.Equal(t, "test-schedule SUCCEEDED Succeeded", slack.messages[1]["text"])
+
+	require.Len(t, teams.messages, 1)
+	assert.Equal(t, "MessageCard", teams.messages[0]["@type"])
+	assert.Equal(t, "Scheduled restart of default/test-schedule succeeded", teams.messages[0]["text"])
+}
+
+func TestNotifierURLFromUnlabeledSecretIsRefused(t *testing.T) {
+	webhook := &incomingWebhook{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-password", Namespace: "default"},
+		Data:       map[string][]byte{"url": []byte(server.URL)},
+	}
+	notifier := &v1alpha1.RestartNotifier{
+		ObjectMeta: metav1.ObjectMeta{Name: "exfiltrate", Namespace: "default"},
+		Spec: v1alpha1.RestartNotifierSpec{
+			URLFrom: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "database-password"},
+				Key:                  "url",
+			},
+		},
+	}
+	reconciler := newTestReconciler(secret)
+
+	data := notificationData{
+		Event:     v1alpha1.NotificationSucceeded,
+		Schedule:  newTestSchedule(),
+		Execution: &v1alpha1.RestartExecution{Result: v1alpha1.ExecutionSucceeded},
+	}
+	err := reconciler.sendNotification(context.Background(), notifier, data)
+	assert.ErrorContains(t, err, "is not labeled "+v1alpha1.WebhookCredentialLabel+"=true")
+	assert.Empty(t, webhook.messages)
+}
+
+func TestRenderNotification(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "shop"}}
+	execution := &v1alpha1.RestartExecution{
+		Result:  v1alpha1.ExecutionFailed,
+		Message: "rollout of Deployment api did not complete",
+	}
+	data := notificationData{Event: v1alpha1.NotificationFailed, Schedule: schedule, Execution: execution}
+
+	text, err := renderNotification("", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Scheduled restart of shop/nightly failed: rollout of Deployment api did not complete", text)
+
+	_, err = renderNotification("{{ .Schedule.Name", data)
+	assert.ErrorContains(t, err, "invalid notification template")
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// policyIndexKey indexes schedules by the kind and name of the policy they
+// reference.
+const policyIndexKey = "spec.policyRef"
+
+func policyKind(ref *v1alpha1.PolicyReference) string {
+	if ref.Kind == "" {
+		return "RestartPolicy"
+	}
+	return ref.Kind
+}
+
+// indexPolicyRef is the field indexer of policyIndexKey.
+func indexPolicyRef(obj client.Object) []string {
+	schedule, ok := obj.(scheduleObject)
+	if !ok || schedule.GetScheduleSpec().PolicyRef == nil {
+		return nil
+	}
+	ref := schedule.GetScheduleSpec().PolicyRef
+	return []string{policyKind(ref) + "/" + ref.Name}
+}
+
+// invalidPolicyError is returned when the policy a schedule references cannot
+// be applied to it.
+type invalidPolicyError struct {
+	message string
+}
+
+func (e *invalidPolicyError) Error() string {
+	return e.message
+}
+
+// applyPolicy fills in the fields of the schedule left unset from the policy
+// it references. It only changes the in-memory copy of the schedule.
+func (r *RestartScheduleReconciler) applyPolicy(ctx context.Context, schedule scheduleObject) error {
+	spec := schedule.GetScheduleSpec()
+	if spec.PolicyRef == nil {
+		return nil
+	}
+
+	if policyKind(spec.PolicyRef) == "ClusterRestartPolicy" {
+		var policy v1alpha1.ClusterRestartPolicy
+		if err := r.Get(ctx, types.NamespacedName{Name: spec.PolicyRef.Name}, &policy); err != nil {
+			return err
+		}
+		mergePolicy(spec, &policy.Spec)
+	} else {
+		var policy v1alpha1.RestartPolicy
+		key := types.NamespacedName{Name: spec.PolicyRef.Name, Namespace: schedule.GetNamespace()}
+		if err := r.Get(ctx, key, &policy); err != nil {
+			return err
+		}
+		mergePolicy(spec, &policy.Spec)
+	}
+
+	// Cluster schedules have no namespace to read the Secret of headerFrom
+	// from. Their own webhooks are validated by the CRD, inherited ones here.
+	if schedule.GetNamespace() == "" {
+		for _, webhook := range spec.Webhooks {
+			if webhook.HeaderFrom != nil {
+				return &invalidPolicyError{message: fmt.Sprintf(
+					"%s %s has webhook %s with headerFrom, which is not supported by cluster schedules",
+					policyKind(spec.PolicyRef), spec.PolicyRef.Name, webhook.Name)}
+			}
+		}
+	}
+	return nil
+}
+
+// mergePolicy sets every field of spec that is unset to the value of the
+// policy. Blackout windows, calendars and webhooks are merged by name instead,
+// with the entries of the schedule replacing those of the policy with the same
+// name, and the excluded dates of both are combined.
+func mergePolicy(spec *v1alpha1.RestartScheduleSpec, policy *v1alpha1.RestartPolicySpec) {
+	if spec.Schedule == "" {
+		spec.Schedule = policy.Schedule
+	}
+	if spec.TimeZone == "" {
+		spec.TimeZone = policy.TimeZone
+	}
+	if spec.Jitter == nil {
+		spec.Jitter = policy.Jitter
+	}
+	if spec.Canary == nil {
+		spec.Canary = policy.Canary
+	}
+	if spec.RolloutTimeout == nil {
+		spec.RolloutTimeout = policy.RolloutTimeout
+	}
+	if spec.HealthGate == nil {
+		spec.HealthGate = policy.HealthGate
+	}
+	if spec.RetryPolicy == nil {
+		spec.RetryPolicy = policy.RetryPolicy
+	}
+	spec.BlackoutWindows = mergeByName(policy.BlackoutWindows, spec.BlackoutWindows,
+		func(window v1alpha1.BlackoutWindow) string { return window.Name })
+	spec.CalendarRefs = mergeByName(policy.CalendarRefs, spec.CalendarRefs,
+		func(ref v1alpha1.CalendarReference) string { return ref.Name })
+	spec.ExcludeDates = mergeByName(policy.ExcludeDates, spec.ExcludeDates,
+		func(date string) string { return date })
+	spec.Webhooks = mergeByName(policy.Webhooks, spec.Webhooks,
+		func(webhook v1alpha1.RestartWebhook) string { return webhook.Name })
+}
+
+// mergeByName returns the inherited items not overridden by an item of the
+// same name, followed by the own items.
+func mergeByName[T any](inherited, own []T, name func(T) string) []T {
+	if len(inherited) == 0 {
+		return own
+	}
+	merged := make([]T, 0, len(inherited)+len(own))
+	for _, item := range inherited {
+		overridden := slices.ContainsFunc(own, func(o T) bool { return name(o) == name(item) })
+		if !overridden {
+			merged = append(merged, item)
+		}
+	}
+	return append(merged, own...)
+}
+
+// schedulesForPolicy returns a map function enqueuing the schedules of the
+// given list type that reference a changed RestartPolicy or
+// ClusterRestartPolicy.
+func (r *RestartScheduleReconciler) schedulesForPolicy(newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		opts := []client.ListOption{client.MatchingFields{policyIndexKey: "ClusterRestartPolicy/" + obj.GetName()}}
+		if _, ok := obj.(*v1alpha1.RestartPolicy); ok {
+			opts = []client.ListOption{
+				client.InNamespace(obj.GetNamespace()),
+				client.MatchingFields{policyIndexKey: "RestartPolicy/" + obj.GetName()},
+			}
+		}
+
+		list := newList()
+		if err := r.List(ctx, list, opts...); err != nil {
+			r.Log.Error(err, "Failed to list schedules for policy", "policy", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, schedule := range scheduleItems(list) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: schedule.GetName(), Namespace: schedule.GetNamespace()},
+			})
+		}
+		return requests
+	}
+}
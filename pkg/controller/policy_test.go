@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMergePolicy(t *testing.T) {
+	policy := &v1alpha1.RestartPolicySpec{
+		Schedule:    "0 3 * * *",
+		TimeZone:    "Europe/Berlin",
+		RetryPolicy: &v1alpha1.RetryPolicy{MaxAttempts: 3},
+		BlackoutWindows: []v1alpha1.BlackoutWindow{
+			{Name: "business-hours", Schedule: "0 9 * * 1-5", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+		},
+		ExcludeDates: []string{"2025-12-24", "2025-12-31"},
+		CalendarRefs: []v1alpha1.CalendarReference{{Name: "public-holidays"}},
+		Webhooks: []v1alpha1.RestartWebhook{
+			{Name: "audit", URL: "https://audit.example.com"},
+			{Name: "chat", URL: "https://chat.example.com"},
+		},
+	}
+	spec := &v1alpha1.RestartScheduleSpec{
+		Schedule:     "30 4 * * *",
+		TargetRef:    &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		ExcludeDates: []string{"2025-12-31", "2026-01-02"},
+		CalendarRefs: []v1alpha1.CalendarReference{{Name: "team-offsite"}},
+		Webhooks: []v1alpha1.RestartWebhook{
+			{Name: "chat", URL: "https://team-chat.example.com"},
+		},
+	}
+
+	mergePolicy(spec, policy)
+
+	assert.Equal(t, "30 4 * * *", spec.Schedule)
+	assert.Equal(t, "Europe/Berlin", spec.TimeZone)
+	assert.Equal(t, int32(3), spec.RetryPolicy.MaxAttempts)
+	assert.Equal(t, policy.BlackoutWindows, spec.BlackoutWindows)
+	assert.Equal(t, []string{"2025-12-24", "2025-12-31", "2026-01-02"}, spec.ExcludeDates)
+	assert.Equal(t, []v1alpha1.CalendarReference{{Name: "public-holidays"}, {Name: "team-offsite"}}, spec.CalendarRefs)
+	assert.Equal(t, []v1alpha1.RestartWebhook{
+		{Name: "audit", URL: "https://audit.example.com"},
+		{Name: "chat", URL: "https://team-chat.example.com"},
+	}, spec.Webhooks)
+}
+
+func TestScheduleFromPolicy(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			PolicyRef: &v1alpha1.PolicyReference{Name: "standard-nightly"},
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	reconciler := newTestReconciler(schedule)
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "api", Namespace: "default"}
+	reconcile := func() *v1alpha1.RestartSchedule {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+		var updated v1alpha1.RestartSchedule
+		require.NoError(t, reconciler.Get(ctx, key, &updated))
+		return &updated
+	}
+
+	updated := reconcile()
+	valid := meta.FindStatusCondition(updated.Status.Conditions, "Valid")
+	require.NotNil(t, valid)
+	assert.Equal(t, metav1.ConditionFalse, valid.Status)
+	assert.Equal(t, "PolicyNotFound", valid.Reason)
+	assert.Empty(t, reconciler.scheduleIDs)
+
+	policy := &v1alpha1.RestartPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard-nightly", Namespace: "default"},
+		Spec:       v1alpha1.RestartPolicySpec{Schedule: "0 3 * * *", TimeZone: "Asia/Tokyo"},
+	}
+	require.NoError(t, reconciler.Create(ctx, policy))
+
+	updated = reconcile()
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "Valid"))
+	assert.Len(t, reconciler.scheduleIDs, 1)
+	require.NotNil(t, updated.Status.NextScheduledTime)
+	assert.Equal(t, 18, updated.Status.NextScheduledTime.UTC().Hour())
+	assert.Len(t, updated.Status.UpcomingRuns, defaultPreviewCount)
+	assert.Empty(t, updated.Spec.Schedule, "the policy must not be written to the schedule")
+
+	requests := reconciler.schedulesForPolicy(func() client.ObjectList { return &v1alpha1.RestartScheduleList{} })(ctx, policy)
+	assert.Equal(t, []ctrl.Request{{NamespacedName: key}}, requests)
+}
+
+func TestRunSkippedDuringBlackoutWindow(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			PolicyRef: &v1alpha1.PolicyReference{Kind: "ClusterRestartPolicy", Name: "frozen"},
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	policy := &v1alpha1.ClusterRestartPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "frozen"},
+		Spec: v1alpha1.RestartPolicySpec{
+			Schedule: "0 3 * * *",
+			BlackoutWindows: []v1alpha1.BlackoutWindow{
+				{Name: "always", Schedule: "* * * * *", Duration: metav1.Duration{Duration: time.Hour}},
+			},
+		},
+	}
+	reconciler := newTestReconciler(schedule, policy)
+
+	cronSchedule, err := cron.ParseStandard("0 3 * * *")
+	require.NoError(t, err)
+	key := types.NamespacedName{Name: "api", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, updated.Status.LastExecution.Result)
+	assert.Contains(t, updated.Status.LastExecution.Message, `blackout window "always"`)
+}
+
+func TestClusterScheduleRejectsInheritedHeaderFrom(t *testing.T) {
+	schedule := clusterTestSchedule(&v1alpha1.ClusterTargetSelector{APIVersion: "apps/v1", Kind: "Deployment"})
+	schedule.Spec.PolicyRef = &v1alpha1.PolicyReference{Kind: "ClusterRestartPolicy", Name: "gated"}
+	policy := &v1alpha1.ClusterRestartPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "gated"},
+		Spec: v1alpha1.RestartPolicySpec{
+			Webhooks: []v1alpha1.RestartWebhook{{
+				Name: "change-freeze",
+				URL:  "https://freeze.example.com",
+				HeaderFrom: &v1alpha1.WebhookHeaderSource{
+					Name:         "Authorization",
+					SecretKeyRef: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token"},
+				},
+			}},
+		},
+	}
+	reconciler := &ClusterRestartScheduleReconciler{RestartScheduleReconciler: newTestReconciler(schedule, policy)}
+
+	key := types.NamespacedName{Name: "ingress-controllers"}
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	var updated v1alpha1.ClusterRestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	valid := meta.FindStatusCondition(updated.Status.Conditions, "Valid")
+	require.NotNil(t, valid)
+	assert.Equal(t, metav1.ConditionFalse, valid.Status)
+	assert.Equal(t, "InvalidPolicy", valid.Reason)
+	assert.Contains(t, valid.Message, "change-freeze")
+	_, exists := reconciler.scheduleIDs[key.String()]
+	assert.False(t, exists, "a schedule with an invalid policy must not run")
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const defaultRolloutTimeout = 10 * time.Minute
+
+// rolloutPollInterval is how often targets are checked while waiting for a
+// rollout to complete.
+var rolloutPollInterval = 5 * time.Second
+
+// targetsError is a failure that can be attributed to some of the targets of
+// a stage, so that only those are reported as failed.
+type targetsError struct {
+	targets []v1alpha1.TargetRef
+	err     error
+}
+
+func (e *targetsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *targetsError) Unwrap() error {
+	return e.err
+}
+
+// waitForRollout blocks until every target has finished rolling out, or
+// returns an error once the timeout expires.
+func (r *RestartScheduleReconciler) waitForRollout(ctx context.Context, targets []v1alpha1.TargetRef, namespace string, timeout time.Duration) error {
+	pending := targets
+	err := wait.PollUntilContextTimeout(ctx, rolloutPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		var notReady []v1alpha1.TargetRef
+		for _, ref := range pending {
+			complete, err := r.targetRolledOut(ctx, ref, targetNamespace(ref, namespace))
+			if err != nil {
+				return false, err
+			}
+			if !complete {
+				notReady = append(notReady, ref)
+			}
+		}
+		pending = notReady
+		return len(pending) == 0, nil
+	})
+	if err != nil && len(pending) > 0 {
+		return &targetsError{
+			targets: pending,
+			err:     fmt.Errorf("rollout of %s %s did not complete: %w", pending[0].Kind, pending[0].Name, err),
+		}
+	}
+	return err
+}
+
+// soak checks the targets for the whole duration and fails as soon as one of
+// them no longer counts as rolled out, e.g. because its pods became unready.
+func (r *RestartScheduleReconciler) soak(ctx context.Context, targets []v1alpha1.TargetRef, namespace string, duration time.Duration) error {
+	deadline := time.Now().Add(duration)
+	for {
+		for _, ref := range targets {
+			healthy, err := r.targetRolledOut(ctx, ref, targetNamespace(ref, namespace))
+			if err != nil {
+				return &targetsError{targets: []v1alpha1.TargetRef{ref}, err: err}
+			}
+			if !healthy {
+				return &targetsError{
+					targets: []v1alpha1.TargetRef{ref},
+					err:     fmt.Errorf("%s %s became unhealthy during the soak period", ref.Kind, ref.Name),
+				}
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(rolloutPollInterval, remaining)):
+		}
+	}
+}
+
+func (r *RestartScheduleReconciler) targetRolledOut(ctx context.Context, ref v1alpha1.TargetRef, namespace string) (bool, error) {
+	target, err := r.getTarget(ctx, ref, namespace)
+	if err != nil {
+		return false, err
+	}
+	return rolloutComplete(target)
+}
+
+// rolloutComplete reports whether the object has finished rolling out its
+// current pod template. Built-in workloads are checked the same way as
+// `kubectl rollout status`; any other kind is considered rolled out once its
+// controller has observed the latest generation and its Ready or Available
+// condition, if it reports one, is true.
+func rolloutComplete(obj *unstructured.Unstructured) (bool, error) {
+	if obj.GroupVersionKind().Group == appsv1.GroupName {
+		switch obj.GetKind() {
+		case "Deployment":
+			var deployment appsv1.Deployment
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &deployment); err != nil {
+				return false, err
+			}
+			return deploymentRolledOut(&deployment), nil
+		case "StatefulSet":
+			var statefulSet appsv1.StatefulSet
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &statefulSet); err != nil {
+				return false, err
+			}
+			return statefulSetRolledOut(&statefulSet), nil
+		case "DaemonSet":
+			var daemonSet appsv1.DaemonSet
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &daemonSet); err != nil {
+				return false, err
+			}
+			return daemonSetRolledOut(&daemonSet), nil
+		}
+	}
+
+	observedGeneration, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil {
+		return false, err
+	}
+	if found && observedGeneration < obj.GetGeneration() {
+		return false, nil
+	}
+
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, err
+	}
+	for _, conditionType := range []string{"Ready", "Available"} {
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+			return condition["status"] == string(metav1.ConditionTrue), nil
+		}
+	}
+	return true, nil
+}
+
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.UpdatedReplicas >= replicas &&
+		deployment.Status.Replicas == deployment.Status.UpdatedReplicas &&
+		deployment.Status.AvailableReplicas >= deployment.Status.UpdatedReplicas
+}
+
+func statefulSetRolledOut(statefulSet *appsv1.StatefulSet) bool {
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+		return false
+	}
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return true
+	}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if statefulSet.Status.ReadyReplicas < replicas {
+		return false
+	}
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+		return statefulSet.Status.UpdatedReplicas >= replicas-*rollingUpdate.Partition
+	}
+	return statefulSet.Status.UpdateRevision == statefulSet.Status.CurrentRevision
+}
+
+func daemonSetRolledOut(daemonSet *appsv1.DaemonSet) bool {
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+		return false
+	}
+	return daemonSet.Status.UpdatedNumberScheduled >= daemonSet.Status.DesiredNumberScheduled &&
+		daemonSet.Status.NumberAvailable >= daemonSet.Status.DesiredNumberScheduled
+}
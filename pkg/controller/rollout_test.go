@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+func toUnstructured(t *testing.T, obj runtime.Object, apiVersion, kind string) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	return u
+}
+
+func TestRolloutComplete(t *testing.T) {
+	tests := []struct {
+		name     string
+		obj      func(t *testing.T) *unstructured.Unstructured
+		complete bool
+	}{
+		{
+			name: "Deployment fully rolled out",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				return toUnstructured(t, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Generation: 2},
+					Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+					Status: appsv1.DeploymentStatus{
+						ObservedGeneration: 2,
+						Replicas:           3,
+						UpdatedReplicas:    3,
+						AvailableReplicas:  3,
+					},
+				}, "apps/v1", "Deployment")
+			},
+			complete: true,
+		},
+		{
+			name: "Deployment with old replicas still running",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				return toUnstructured(t, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Generation: 2},
+					Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+					Status: appsv1.DeploymentStatus{
+						ObservedGeneration: 2,
+						Replicas:           4,
+						UpdatedReplicas:    3,
+						AvailableReplicas:  3,
+					},
+				}, "apps/v1", "Deployment")
+			},
+			complete: false,
+		},
+		{
+			name: "Deployment generation not observed yet",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				return toUnstructured(t, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Generation: 3},
+					Status:     appsv1.DeploymentStatus{ObservedGeneration: 2},
+				}, "apps/v1", "Deployment")
+			},
+			complete: false,
+		},
+		{
+			name: "StatefulSet with pending revision",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				return toUnstructured(t, &appsv1.StatefulSet{
+					Spec: appsv1.StatefulSetSpec{Replicas: ptr.To[int32](2)},
+					Status: appsv1.StatefulSetStatus{
+						ReadyReplicas:   2,
+						CurrentRevision: "db-1",
+						UpdateRevision:  "db-2",
+					},
+				}, "apps/v1", "StatefulSet")
+			},
+			complete: false,
+		},
+		{
+			name: "DaemonSet fully rolled out",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				return toUnstructured(t, &appsv1.DaemonSet{
+					Status: appsv1.DaemonSetStatus{
+						DesiredNumberScheduled: 4,
+						UpdatedNumberScheduled: 4,
+						NumberAvailable:        4,
+					},
+				}, "apps/v1", "DaemonSet")
+			},
+			complete: true,
+		},
+		{
+			name: "Custom kind with Ready condition false",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				u := &unstructured.Unstructured{Object: map[string]interface{}{
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{"type": "Ready", "status": "False"},
+						},
+					},
+				}}
+				u.SetAPIVersion("example.com/v1")
+				u.SetKind("Widget")
+				return u
+			},
+			complete: false,
+		},
+		{
+			name: "Custom kind without status",
+			obj: func(t *testing.T) *unstructured.Unstructured {
+				u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+				u.SetAPIVersion("example.com/v1")
+				u.SetKind("Widget")
+				return u
+			},
+			complete: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete, err := rolloutComplete(tt.obj(t))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.complete, complete)
+		})
+	}
+}
+
+func TestWaitForRolloutReportsPendingTargets(t *testing.T) {
+	pollFast(t)
+	ready := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+		Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	stuck := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "stuck", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+	}
+	reconciler := newTestReconciler(ready, stuck)
+
+	targets := []v1alpha1.TargetRef{
+		{Kind: "Deployment", Name: "ready"},
+		{Kind: "Deployment", Name: "stuck"},
+	}
+	err := reconciler.waitForRollout(context.Background(), targets, "default", 20*time.Millisecond)
+
+	var targetsErr *targetsError
+	require.ErrorAs(t, err, &targetsErr)
+	assert.Equal(t, []v1alpha1.TargetRef{{Kind: "Deployment", Name: "stuck"}}, targetsErr.targets)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// scheduleObject is what the restart engine runs: a RestartSchedule or a
+// ClusterRestartSchedule.
+type scheduleObject interface {
+	client.Object
+	GetScheduleSpec() *v1alpha1.RestartScheduleSpec
+	GetScheduleStatus() *v1alpha1.RestartScheduleStatus
+}
+
+func scheduleKind(schedule scheduleObject) string {
+	if _, ok := schedule.(*v1alpha1.ClusterRestartSchedule); ok {
+		return "ClusterRestartSchedule"
+	}
+	return "RestartSchedule"
+}
+
+// scheduleName names the schedule in messages: namespace/name for a
+// RestartSchedule and just the name for a ClusterRestartSchedule.
+func scheduleName(schedule scheduleObject) string {
+	if schedule.GetNamespace() == "" {
+		return schedule.GetName()
+	}
+	return schedule.GetNamespace() + "/" + schedule.GetName()
+}
+
+// emptySchedule returns an empty object of the kind of schedule.
+func emptySchedule(schedule scheduleObject) scheduleObject {
+	if _, ok := schedule.(*v1alpha1.ClusterRestartSchedule); ok {
+		return &v1alpha1.ClusterRestartSchedule{}
+	}
+	return &v1alpha1.RestartSchedule{}
+}
+
+func copySchedule(schedule scheduleObject) scheduleObject {
+	return schedule.DeepCopyObject().(scheduleObject)
+}
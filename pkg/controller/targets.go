@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	restartedAtAnnotation   = "restart-operator.k8s/restartedAt"
+	restartedByAnnotation   = "restart-operator.k8s/restartedBy"
+	restartReasonAnnotation = "restart-operator.k8s/restartReason"
+	executionIDAnnotation   = "restart-operator.k8s/executionID"
+
+	// fieldManager is recorded in managedFields for every write the operator
+	// makes, so GitOps tools can tell its changes apart from their own.
+	fieldManager = "restart-operator"
+)
+
+// errUnsupportedKind is wrapped by the errors of TargetRefs that cannot be
+// resolved to a kind.
+var errUnsupportedKind = errors.New("unsupported resource kind")
+
+// defaultTemplatePath is where the pod template lives for kinds that are not
+// explicitly registered.
+var defaultTemplatePath = []string{"spec", "template"}
+
+// AnnotationKeys are the keys of the annotations written to the pod template
+// of a restarted target. Empty keys fall back to the defaults.
+type AnnotationKeys struct {
+	// RestartedAt holds the time of the restart.
+	RestartedAt string
+	// RestartedBy holds the namespace/name of the schedule.
+	RestartedBy string
+	// RestartReason holds why the restart happened, e.g. schedule.
+	RestartReason string
+	// ExecutionID holds the ID of the execution in the schedule status.
+	ExecutionID string
+}
+
+func (k AnnotationKeys) withDefaults() AnnotationKeys {
+	if k.RestartedAt == "" {
+		k.RestartedAt = restartedAtAnnotation
+	}
+	if k.RestartedBy == "" {
+		k.RestartedBy = restartedByAnnotation
+	}
+	if k.RestartReason == "" {
+		k.RestartReason = restartReasonAnnotation
+	}
+	if k.ExecutionID == "" {
+		k.ExecutionID = executionIDAnnotation
+	}
+	return k
+}
+
+// TargetKind describes how a kind of workload is restarted: which version is
+// assumed when a TargetRef omits apiVersion, and where its pod template lives.
+type TargetKind struct {
+	GroupVersionKind schema.GroupVersionKind
+	TemplatePath     []string
+}
+
+// TargetKindRegistry holds the kinds the operator knows how to restart.
+// Kinds that are not registered can still be targeted as long as the
+// TargetRef carries an apiVersion and the pod template lives at spec.template.
+type TargetKindRegistry struct {
+	kinds map[schema.GroupKind]TargetKind
+}
+
+func NewTargetKindRegistry() *TargetKindRegistry {
+	registry := &TargetKindRegistry{kinds: make(map[schema.GroupKind]TargetKind)}
+	for _, kind := range []string{"Deployment", "StatefulSet", "DaemonSet"} {
+		registry.Register(TargetKind{
+			GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind},
+			TemplatePath:     defaultTemplatePath,
+		})
+	}
+	return registry
+}
+
+func (r *TargetKindRegistry) Register(kind TargetKind) {
+	if len(kind.TemplatePath) == 0 {
+		kind.TemplatePath = defaultTemplatePath
+	}
+	r.kinds[kind.GroupVersionKind.GroupKind()] = kind
+}
+
+// Lookup resolves a TargetRef to the GroupVersionKind to fetch and the path of
+// its pod template. A TargetRef without apiVersion only resolves if a single
+// group registers its kind with a version.
+func (r *TargetKindRegistry) Lookup(ref v1alpha1.TargetRef) (schema.GroupVersionKind, []string, error) {
+	if ref.APIVersion == "" {
+		var matches []TargetKind
+		for _, kind := range r.kinds {
+			if kind.GroupVersionKind.Kind == ref.Kind && kind.GroupVersionKind.Version != "" {
+				matches = append(matches, kind)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return schema.GroupVersionKind{}, nil, fmt.Errorf("%w: %s (apiVersion is required for kinds that are not built in)", errUnsupportedKind, ref.Kind)
+		case 1:
+			return matches[0].GroupVersionKind, matches[0].TemplatePath, nil
+		}
+		groups := make([]string, 0, len(matches))
+		for _, kind := range matches {
+			groups = append(groups, kind.GroupVersionKind.Group)
+		}
+		slices.Sort(groups)
+		return schema.GroupVersionKind{}, nil, fmt.Errorf("%w: %s (apiVersion is required, the kind is registered in groups %s)",
+			errUnsupportedKind, ref.Kind, strings.Join(groups, ", "))
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return schema.GroupVersionKind{}, nil, fmt.Errorf("%w: %s (invalid apiVersion %q: %v)", errUnsupportedKind, ref.Kind, ref.APIVersion, err)
+	}
+	gvk := gv.WithKind(ref.Kind)
+
+	if kind, ok := r.kinds[gvk.GroupKind()]; ok {
+		return gvk, kind.TemplatePath, nil
+	}
+	return gvk, defaultTemplatePath, nil
+}
+
+// LoadTargetKindsFromConfigMap registers template path overrides from a
+// ConfigMap. Each key is a kind qualified by its group the way kubectl spells
+// it (e.g. "Service.serving.knative.dev", or just "Pod" for the core group)
+// and each value is the dotted path of the pod template (e.g. "spec.template").
+func LoadTargetKindsFromConfigMap(ctx context.Context, reader client.Reader, key types.NamespacedName, registry *TargetKindRegistry) error {
+	var configMap corev1.ConfigMap
+	if err := reader.Get(ctx, key, &configMap); err != nil {
+		return fmt.Errorf("failed to get target kinds ConfigMap %s: %w", key, err)
+	}
+
+	for qualifiedKind, path := range configMap.Data {
+		gk := schema.ParseGroupKind(qualifiedKind)
+		if gk.Kind == "" {
+			return fmt.Errorf("invalid kind %q in ConfigMap %s", qualifiedKind, key)
+		}
+
+		templatePath := strings.Split(strings.TrimSpace(path), ".")
+		for _, field := range templatePath {
+			if field == "" {
+				return fmt.Errorf("invalid template path %q for kind %q in ConfigMap %s", path, qualifiedKind, key)
+			}
+		}
+
+		version := ""
+		if existing, ok := registry.kinds[gk]; ok {
+			version = existing.GroupVersionKind.Version
+		}
+		registry.Register(TargetKind{
+			GroupVersionKind: gk.WithVersion(version),
+			TemplatePath:     templatePath,
+		})
+	}
+	return nil
+}
+
+func (r *RestartScheduleReconciler) targetKinds() *TargetKindRegistry {
+	if r.TargetKinds == nil {
+		return NewTargetKindRegistry()
+	}
+	return r.TargetKinds
+}
+
+// targetNamespace returns the namespace of the target, which defaults to the
+// namespace of the schedule referring to it.
+func targetNamespace(ref v1alpha1.TargetRef, scheduleNamespace string) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return scheduleNamespace
+}
+
+// restartTarget bumps the restartedAt annotation on the pod template of the
+// referenced object with a JSON merge patch, which makes the owning controller
+// roll out new pods. The given annotations are set alongside it. A dry run
+// sends the patch as a server-side dry run, so admission still validates it
+// but nothing is persisted. The target is returned whenever it could be read,
+// even if restarting it failed.
+func (r *RestartScheduleReconciler) restartTarget(
+	ctx context.Context,
+	ref v1alpha1.TargetRef,
+	namespace string,
+	annotations map[string]string,
+	dryRun bool,
+) (*unstructured.Unstructured, error) {
+	logger := r.Log.WithValues("kind", ref.Kind, "name", ref.Name, "namespace", namespace, "dryRun", dryRun)
+	target, templatePath, err := r.getTargetTemplate(ctx, ref, namespace)
+	if err != nil {
+		logger.Error(err, "Failed to get target")
+		return nil, err
+	}
+	logger = logger.WithValues("apiVersion", target.GetAPIVersion())
+	logger.Info("Restarting target")
+
+	_, found, err := unstructured.NestedMap(target.Object, templatePath...)
+	if err != nil || !found {
+		err = fmt.Errorf("%s %s/%s has no pod template at %s", ref.Kind, namespace, ref.Name, strings.Join(templatePath, "."))
+		logger.Error(err, "Failed to locate pod template")
+		return target, err
+	}
+
+	templateAnnotations := map[string]string{
+		r.Annotations.withDefaults().RestartedAt: time.Now().Format(time.RFC3339),
+	}
+	for key, value := range annotations {
+		templateAnnotations[key] = value
+	}
+	patch, err := templateAnnotationsPatch(templatePath, templateAnnotations)
+	if err != nil {
+		return target, err
+	}
+
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	err = retry.OnError(retry.DefaultBackoff, isRetryable, func() error {
+		return r.Patch(ctx, target, client.RawPatch(types.MergePatchType, patch), opts...)
+	})
+	if err != nil {
+		logger.Error(err, "Failed to patch target")
+		return target, err
+	}
+
+	if dryRun {
+		logger.Info("Target would have been restarted")
+		return target, nil
+	}
+	logger.Info("Successfully restarted target")
+	return target, nil
+}
+
+// isRetryable reports whether a failed write is worth retrying: conflicts and
+// errors signalling that the API server is overloaded or slow to respond.
+func isRetryable(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err)
+}
+
+// templateAnnotationsPatch builds a merge patch of the annotations of the pod
+// template found at templatePath. Annotations set to nil are removed.
+func templateAnnotationsPatch(templatePath []string, annotations interface{}) ([]byte, error) {
+	var patch interface{} = map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+	for i := len(templatePath) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{templatePath[i]: patch}
+	}
+	return json.Marshal(patch)
+}
+
+// removeRestartAnnotations removes the annotations written by restartTarget
+// from the pod template of the referenced object, unless they were last
+// written by a schedule other than restartedBy. Removing them changes the pod
+// template, so the owning controller rolls out new pods once more. With dryRun
+// the patch is only validated by the API server.
+func (r *RestartScheduleReconciler) removeRestartAnnotations(
+	ctx context.Context,
+	ref v1alpha1.TargetRef,
+	namespace string,
+	restartedBy string,
+	dryRun bool,
+) error {
+	target, templatePath, err := r.getTargetTemplate(ctx, ref, namespace)
+	if err != nil {
+		return err
+	}
+
+	keys := r.Annotations.withDefaults()
+	current, _, err := unstructured.NestedStringMap(target.Object, append(slices.Clone(templatePath), "metadata", "annotations")...)
+	if err != nil {
+		return err
+	}
+	if by, ok := current[keys.RestartedBy]; ok && by != restartedBy {
+		return nil
+	}
+
+	remove := map[string]interface{}{}
+	for _, key := range []string{keys.RestartedAt, keys.RestartedBy, keys.RestartReason, keys.ExecutionID} {
+		if _, ok := current[key]; ok {
+			remove[key] = nil
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+
+	patch, err := templateAnnotationsPatch(templatePath, remove)
+	if err != nil {
+		return err
+	}
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	r.Log.Info("Removing restart annotations", "kind", ref.Kind, "name", ref.Name, "namespace", namespace, "dryRun", dryRun)
+	return retry.OnError(retry.DefaultBackoff, isRetryable, func() error {
+		return r.Patch(ctx, target, client.RawPatch(types.MergePatchType, patch), opts...)
+	})
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestTargetKindLookup(t *testing.T) {
+	registry := NewTargetKindRegistry()
+	registry.Register(TargetKind{GroupVersionKind: schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}})
+	registry.Register(TargetKind{GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Rollout"}})
+
+	tests := []struct {
+		name         string
+		ref          v1alpha1.TargetRef
+		expectedGVK  schema.GroupVersionKind
+		expectedPath []string
+		shouldError  bool
+	}{
+		{
+			name:         "Built-in kind without apiVersion",
+			ref:          v1alpha1.TargetRef{Kind: "StatefulSet", Name: "db"},
+			expectedGVK:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+			expectedPath: []string{"spec", "template"},
+		},
+		{
+			name:         "Unregistered kind with apiVersion",
+			ref:          v1alpha1.TargetRef{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"},
+			expectedGVK:  schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+			expectedPath: []string{"spec", "template"},
+		},
+		{
+			name:        "Unregistered kind without apiVersion",
+			ref:         v1alpha1.TargetRef{Kind: "ReplicaSet", Name: "rs"},
+			shouldError: true,
+		},
+		{
+			name:        "Kind of several groups without apiVersion",
+			ref:         v1alpha1.TargetRef{Kind: "Rollout", Name: "api"},
+			shouldError: true,
+		},
+		{
+			name:         "Kind of several groups with apiVersion",
+			ref:          v1alpha1.TargetRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "api"},
+			expectedGVK:  schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"},
+			expectedPath: []string{"spec", "template"},
+		},
+		{
+			name:        "Invalid apiVersion",
+			ref:         v1alpha1.TargetRef{APIVersion: "a/b/c", Kind: "Widget", Name: "w"},
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gvk, path, err := registry.Lookup(tt.ref)
+			if tt.shouldError {
+				assert.ErrorIs(t, err, errUnsupportedKind)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedGVK, gvk)
+			assert.Equal(t, tt.expectedPath, path)
+		})
+	}
+}
+
+func TestLoadTargetKindsFromConfigMap(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = corev1.AddToScheme(s)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "target-kinds",
+			Namespace: "restart-operator",
+		},
+		Data: map[string]string{
+			"Widget.example.com": "spec.workload.template",
+		},
+	}
+	mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(configMap).Build()
+
+	registry := NewTargetKindRegistry()
+	err := LoadTargetKindsFromConfigMap(context.Background(), mockClient,
+		types.NamespacedName{Name: "target-kinds", Namespace: "restart-operator"}, registry)
+	require.NoError(t, err)
+
+	_, path, err := registry.Lookup(v1alpha1.TargetRef{APIVersion: "example.com/v1", Kind: "Widget", Name: "w"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spec", "workload", "template"}, path)
+
+	configMap.Data = map[string]string{"Widget.example.com": "spec..template"}
+	mockClient = fake.NewClientBuilder().WithScheme(s).WithObjects(configMap).Build()
+	err = LoadTargetKindsFromConfigMap(context.Background(), mockClient,
+		types.NamespacedName{Name: "target-kinds", Namespace: "restart-operator"}, NewTargetKindRegistry())
+	assert.Error(t, err)
+}
+
+func TestGenericTargetRestart(t *testing.T) {
+	widget := &unstructured.Unstructured{}
+	widget.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	widget.SetName("test-widget")
+	widget.SetNamespace("default")
+	_ = unstructured.SetNestedMap(widget.Object, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app": "widget"},
+		},
+	}, "spec", "workload", "template")
+
+	mockClient := fake.NewClientBuilder().WithObjects(widget).Build()
+
+	registry := NewTargetKindRegistry()
+	registry.Register(TargetKind{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.com", Kind: "Widget"},
+		TemplatePath:     []string{"spec", "workload", "template"},
+	})
+
+	reconciler := &RestartScheduleReconciler{
+		Client:      mockClient,
+		Recorder:    record.NewFakeRecorder(10),
+		Log:         logf.Log.WithName("test-logger"),
+		TargetKinds: registry,
+	}
+
+	ref := v1alpha1.TargetRef{APIVersion: "example.com/v1", Kind: "Widget", Name: "test-widget"}
+	_, err := reconciler.restartTarget(context.Background(), ref, "default", nil, false)
+	require.NoError(t, err)
+
+	updated := &unstructured.Unstructured{}
+	updated.SetGroupVersionKind(widget.GroupVersionKind())
+	err = mockClient.Get(context.Background(), types.NamespacedName{Name: "test-widget", Namespace: "default"}, updated)
+	require.NoError(t, err)
+
+	annotations, _, _ := unstructured.NestedStringMap(updated.Object,
+		"spec", "workload", "template", "metadata", "annotations")
+	assert.Contains(t, annotations, restartedAtAnnotation)
+
+	labels, _, _ := unstructured.NestedStringMap(updated.Object,
+		"spec", "workload", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"app": "widget"}, labels)
+
+	registry = NewTargetKindRegistry()
+	reconciler.TargetKinds = registry
+	_, err = reconciler.restartTarget(context.Background(), ref, "default", nil, false)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errUnsupportedKind)
+}
+
+func TestRestartTargetRetriesOnConflict(t *testing.T) {
+	s := runtime.NewScheme()
+	_ = appsv1.AddToScheme(s)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-deployment",
+			Namespace: "default",
+		},
+	}
+
+	patchCalls := 0
+	mockClient := fake.NewClientBuilder().
+		WithScheme(s).
+		WithObjects(deployment).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patchCalls++
+				if patchCalls == 1 {
+					return apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"},
+						obj.GetName(), fmt.Errorf("the object has been modified"))
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	reconciler := &RestartScheduleReconciler{
+		Client:   mockClient,
+		Recorder: record.NewFakeRecorder(10),
+		Log:      logf.Log.WithName("test-logger"),
+	}
+
+	_, err := reconciler.restartTarget(context.Background(),
+		v1alpha1.TargetRef{Kind: "Deployment", Name: "test-deployment"}, "default", nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, patchCalls)
+
+	updated := &appsv1.Deployment{}
+	err = mockClient.Get(context.Background(), types.NamespacedName{Name: "test-deployment", Namespace: "default"}, updated)
+	require.NoError(t, err)
+	assert.Contains(t, updated.Spec.Template.Annotations, restartedAtAnnotation)
+}
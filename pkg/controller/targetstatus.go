@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// targetIndexKey indexes schedules by the kind, namespace and name of each of
+// their targets. ClusterRestartSchedules with a target selector are indexed
+// under the selected kind with a wildcard namespace and name.
+const targetIndexKey = "spec.targets"
+
+const anyTarget = "*"
+
+// watchedTargetKinds are the kinds whose changes refresh the status of the
+// schedules targeting them.
+var watchedTargetKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+func targetIndexValue(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// indexTargets is the field indexer of targetIndexKey.
+func indexTargets(obj client.Object) []string {
+	schedule, ok := obj.(scheduleObject)
+	if !ok {
+		return nil
+	}
+
+	var values []string
+	if cluster, ok := obj.(*v1alpha1.ClusterRestartSchedule); ok && cluster.Spec.TargetSelector != nil {
+		values = append(values, targetIndexValue(cluster.Spec.TargetSelector.Kind, anyTarget, anyTarget))
+	}
+	for _, ref := range scheduleTargets(schedule.GetScheduleSpec()) {
+		values = append(values, targetIndexValue(ref.Kind, targetNamespace(ref, schedule.GetNamespace()), ref.Name))
+	}
+	return values
+}
+
+// schedulesForTarget returns a map function enqueuing the schedules of the
+// given list type that target a changed workload of the kind. Schedules with a
+// target selector are only enqueued for the workloads it selects.
+func (r *RestartScheduleReconciler) schedulesForTarget(kind string, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var requests []reconcile.Request
+		for _, value := range []string{
+			targetIndexValue(kind, obj.GetNamespace(), obj.GetName()),
+			targetIndexValue(kind, anyTarget, anyTarget),
+		} {
+			list := newList()
+			if err := r.List(ctx, list, client.MatchingFields{targetIndexKey: value}); err != nil {
+				r.Log.Error(err, "Failed to list schedules for target",
+					"targetKind", kind, "target", client.ObjectKeyFromObject(obj))
+				continue
+			}
+			for _, schedule := range scheduleItems(list) {
+				if value != targetIndexValue(kind, anyTarget, anyTarget) {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Name: schedule.GetName(), Namespace: schedule.GetNamespace()},
+					})
+					continue
+				}
+				cluster, ok := schedule.(*v1alpha1.ClusterRestartSchedule)
+				if !ok {
+					continue
+				}
+				// When the selector cannot be evaluated the schedule is
+				// enqueued anyway, and its reconcile reports the problem.
+				selected, err := r.selectsWorkload(ctx, cluster, obj)
+				if err != nil {
+					r.Log.Error(err, "Failed to match target selector",
+						"clusterrestartschedule", cluster.GetName(), "target", client.ObjectKeyFromObject(obj))
+				} else if !selected {
+					continue
+				}
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: schedule.GetName(), Namespace: schedule.GetNamespace()},
+				})
+			}
+		}
+		return requests
+	}
+}
+
+func scheduleItems(list client.ObjectList) []client.Object {
+	var items []client.Object
+	switch list := list.(type) {
+	case *v1alpha1.RestartScheduleList:
+		for i := range list.Items {
+			items = append(items, &list.Items[i])
+		}
+	case *v1alpha1.ClusterRestartScheduleList:
+		for i := range list.Items {
+			items = append(items, &list.Items[i])
+		}
+	}
+	return items
+}
+
+// refreshTargetStatus looks up the targets of the schedule and reports them
+// in the TargetFound and TargetReady conditions and in status.target.
+func (r *RestartScheduleReconciler) refreshTargetStatus(ctx context.Context, schedule scheduleObject) error {
+	status := schedule.GetScheduleStatus()
+	now := metav1.Now()
+
+	resolved := copySchedule(schedule)
+	if err := r.resolveTargets(ctx, resolved); err != nil {
+		var skipped *skipError
+		if !errors.As(err, &skipped) {
+			return err
+		}
+		status.Target = nil
+		for _, conditionType := range []string{"TargetFound", "TargetReady"} {
+			applyCondition(schedule, metav1.Condition{
+				Type:               conditionType,
+				Status:             metav1.ConditionFalse,
+				Reason:             skipped.reason,
+				Message:            skipped.message,
+				LastTransitionTime: now,
+			})
+		}
+		return nil
+	}
+
+	targets := scheduleTargets(resolved.GetScheduleSpec())
+	summary := &v1alpha1.TargetStatus{}
+	var missing, notReady []string
+	for _, ref := range targets {
+		namespace := targetNamespace(ref, schedule.GetNamespace())
+		name := fmt.Sprintf("%s %s/%s", ref.Kind, namespace, ref.Name)
+
+		target, err := r.getTarget(ctx, ref, namespace)
+		if apierrors.IsNotFound(err) {
+			missing = append(missing, name)
+			continue
+		}
+		if errors.Is(err, errUnsupportedKind) {
+			missing = append(missing, fmt.Sprintf("%s (%v)", name, err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		replicas, ready := targetReplicas(target)
+		summary.Replicas += replicas
+		summary.ReadyReplicas += ready
+		if len(targets) == 1 {
+			if summary.Revision, err = revisionOf(target); err != nil {
+				return err
+			}
+		}
+
+		complete, err := rolloutComplete(target)
+		if err != nil {
+			return err
+		}
+		if !complete {
+			notReady = append(notReady, name)
+		}
+	}
+
+	found := metav1.Condition{
+		Type:               "TargetFound",
+		Status:             metav1.ConditionTrue,
+		Reason:             "Found",
+		Message:            "All targets exist",
+		LastTransitionTime: now,
+	}
+	ready := metav1.Condition{
+		Type:               "TargetReady",
+		Status:             metav1.ConditionTrue,
+		Reason:             "Ready",
+		Message:            "All targets are rolled out and ready",
+		LastTransitionTime: now,
+	}
+	switch {
+	case len(missing) > 0:
+		found.Status = metav1.ConditionFalse
+		found.Reason = "NotFound"
+		found.Message = "Not found: " + strings.Join(missing, ", ")
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "TargetNotFound"
+		ready.Message = found.Message
+	case len(notReady) > 0:
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = "NotReady"
+		ready.Message = "Not ready: " + strings.Join(notReady, ", ")
+	}
+	applyCondition(schedule, found)
+	applyCondition(schedule, ready)
+
+	if len(missing) == len(targets) {
+		status.Target = nil
+	} else {
+		status.Target = summary
+	}
+	return nil
+}
+
+func (r *RestartScheduleReconciler) getTarget(ctx context.Context, ref v1alpha1.TargetRef, namespace string) (*unstructured.Unstructured, error) {
+	target, _, err := r.getTargetTemplate(ctx, ref, namespace)
+	return target, err
+}
+
+// getTargetTemplate fetches the target along with the path of its pod
+// template.
+func (r *RestartScheduleReconciler) getTargetTemplate(
+	ctx context.Context,
+	ref v1alpha1.TargetRef,
+	namespace string,
+) (*unstructured.Unstructured, []string, error) {
+	gvk, templatePath, err := r.targetKinds().Lookup(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(gvk)
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, target); err != nil {
+		return nil, nil, err
+	}
+	return target, templatePath, nil
+}
+
+// targetReplicas returns the number of pods the target should run and how
+// many of them are ready. DaemonSets report these as scheduled and ready
+// numbers, everything else as replicas.
+func targetReplicas(target *unstructured.Unstructured) (int32, int32) {
+	if target.GetKind() == "DaemonSet" {
+		desired, _, _ := unstructured.NestedInt64(target.Object, "status", "desiredNumberScheduled")
+		ready, _, _ := unstructured.NestedInt64(target.Object, "status", "numberReady")
+		return int32(desired), int32(ready)
+	}
+
+	replicas, found, _ := unstructured.NestedInt64(target.Object, "spec", "replicas")
+	if !found {
+		replicas, _, _ = unstructured.NestedInt64(target.Object, "status", "replicas")
+	}
+	ready, _, _ := unstructured.NestedInt64(target.Object, "status", "readyReplicas")
+	return int32(replicas), int32(ready)
+}
+
+// watchTargets adds metadata-only watches on the built-in workload kinds that
+// enqueue the schedules of the given list type targeting a changed workload.
+func (r *RestartScheduleReconciler) watchTargets(bldr *builder.Builder, newList func() client.ObjectList) *builder.Builder {
+	for _, kind := range watchedTargetKinds {
+		target := &metav1.PartialObjectMetadata{}
+		target.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(kind))
+		bldr = bldr.Watches(target, handler.EnqueueRequestsFromMapFunc(r.schedulesForTarget(kind, newList)))
+	}
+	return bldr
+}
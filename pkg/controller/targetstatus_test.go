@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTargetConditionsFollowTarget(t *testing.T) {
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-schedule", Namespace: "default", Generation: 3},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 3 * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	reconciler := newTestReconciler(schedule)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconcile := func() *v1alpha1.RestartSchedule {
+		_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+		var updated v1alpha1.RestartSchedule
+		require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+		return &updated
+	}
+
+	updated := reconcile()
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "Valid"))
+	found := meta.FindStatusCondition(updated.Status.Conditions, "TargetFound")
+	require.NotNil(t, found)
+	assert.Equal(t, metav1.ConditionFalse, found.Status)
+	assert.Equal(t, "NotFound", found.Reason)
+	assert.Contains(t, found.Message, "Deployment default/api")
+	assert.Nil(t, updated.Status.Target)
+	assert.Equal(t, updated.Generation, updated.Status.ObservedGeneration)
+	scheduleReady := meta.FindStatusCondition(updated.Status.Conditions, "Ready")
+	require.NotNil(t, scheduleReady)
+	assert.Equal(t, metav1.ConditionFalse, scheduleReady.Status)
+	assert.Equal(t, "TargetNotFound", scheduleReady.Reason)
+	assert.Equal(t, updated.Generation, scheduleReady.ObservedGeneration)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			Annotations: map[string]string{deploymentRevisionAnnotation: "4"},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: ptr.To(int32(3))},
+	}
+	require.NoError(t, reconciler.Create(context.Background(), deployment))
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 2, AvailableReplicas: 2}
+	require.NoError(t, reconciler.Status().Update(context.Background(), deployment))
+
+	updated = reconcile()
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "TargetFound"))
+	ready := meta.FindStatusCondition(updated.Status.Conditions, "TargetReady")
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, "NotReady", ready.Reason)
+	assert.Equal(t, &v1alpha1.TargetStatus{Replicas: 3, ReadyReplicas: 2, Revision: "4"}, updated.Status.Target)
+	// A rollout in progress does not make the schedule unready.
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "Ready"))
+
+	deployment.Status.ReadyReplicas = 3
+	deployment.Status.AvailableReplicas = 3
+	require.NoError(t, reconciler.Status().Update(context.Background(), deployment))
+
+	updated = reconcile()
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, "TargetReady"))
+	assert.Equal(t, int32(3), updated.Status.Target.ReadyReplicas)
+}
+
+func TestSchedulesForTarget(t *testing.T) {
+	api := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 3 * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+		},
+	}
+	remote := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "ops"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 3 * * *",
+			Stages: []v1alpha1.RestartStage{{
+				Name: "all",
+				Targets: []v1alpha1.TargetRef{
+					{Kind: "StatefulSet", Name: "db", Namespace: "default"},
+					{Kind: "Deployment", Name: "api", Namespace: "default"},
+				},
+			}},
+		},
+	}
+	other := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 3 * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "StatefulSet", Name: "api"},
+		},
+	}
+	reconciler := newTestReconciler(api, remote, other)
+
+	assert.Equal(t, []string{"StatefulSet/default/db", "Deployment/default/api"}, indexTargets(remote))
+
+	target := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	mapTarget := reconciler.schedulesForTarget("Deployment",
+		func() client.ObjectList { return &v1alpha1.RestartScheduleList{} })
+
+	assert.ElementsMatch(t, []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Name: "api", Namespace: "default"}},
+		{NamespacedName: types.NamespacedName{Name: "remote", Namespace: "ops"}},
+	}, mapTarget(context.Background(), target))
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const tracerName = "github.com/archsyscall/restart-operator/pkg/controller"
+
+// startSpan starts a span named after the operation for the schedule with the
+// given key. It uses the reconciler's TracerProvider, or the global one when
+// none is set.
+func (r *RestartScheduleReconciler) startSpan(
+	ctx context.Context,
+	name string,
+	key types.NamespacedName,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	provider := r.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	attrs = append([]attribute.KeyValue{
+		attribute.String("restartschedule.namespace", key.Namespace),
+		attribute.String("restartschedule.name", key.Name),
+	}, attrs...)
+	return provider.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// targetAttributes describes a target on a span.
+func targetAttributes(ref v1alpha1.TargetRef, namespace string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("target.kind", ref.Kind),
+		attribute.String("target.name", ref.Name),
+		attribute.String("target.namespace", namespace),
+	}
+}
+
+// endSpan records err on the span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	setSpanError(span, err)
+	span.End()
+}
+
+func setSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func spanByName(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %s", name)
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestRestartExecutionSpans(t *testing.T) {
+	pollFast(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	schedule := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-schedule",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule: "0 * * * *",
+			Stages: []v1alpha1.RestartStage{
+				{Name: "backend", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "api"}}},
+				{Name: "frontend", Targets: []v1alpha1.TargetRef{{Kind: "Deployment", Name: "web"}}},
+			},
+		},
+	}
+	rolledOut := appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	api := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}, Status: rolledOut}
+	reconciler := newTestReconciler(schedule, api)
+	reconciler.TracerProvider = provider
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	spans := exporter.GetSpans().Snapshots()
+
+	root := spanByName(t, spans, "RestartExecution")
+	assert.False(t, root.Parent().IsValid())
+	assert.Equal(t, codes.Error, root.Status().Code)
+	assert.Equal(t, "Failed", spanAttribute(root, "restart.result"))
+	assert.Equal(t, "test-schedule", spanAttribute(root, "restartschedule.name"))
+
+	var stages []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() == "Stage" {
+			stages = append(stages, span)
+			assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID())
+		}
+	}
+	require.Len(t, stages, 2)
+	assert.Equal(t, "backend", spanAttribute(stages[0], "stage.name"))
+	assert.Equal(t, codes.Unset, stages[0].Status().Code)
+	assert.Equal(t, "frontend", spanAttribute(stages[1], "stage.name"))
+	assert.Equal(t, codes.Error, stages[1].Status().Code)
+
+	rollout := spanByName(t, spans, "WaitForRollout")
+	assert.Equal(t, stages[0].SpanContext().SpanID(), rollout.Parent().SpanID())
+
+	var targets []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() == "RestartTarget" {
+			targets = append(targets, span)
+		}
+	}
+	require.Len(t, targets, 2)
+	assert.Equal(t, "api", spanAttribute(targets[0], "target.name"))
+	assert.Equal(t, stages[0].SpanContext().SpanID(), targets[0].Parent().SpanID())
+	assert.Equal(t, "web", spanAttribute(targets[1], "target.name"))
+	assert.Equal(t, codes.Error, targets[1].Status().Code)
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// skipError is returned when an execution was deliberately not carried out,
+// as opposed to having failed.
+type skipError struct {
+	reason  string
+	message string
+}
+
+func (e *skipError) Error() string {
+	return e.message
+}
+
+// scheduleReference identifies a schedule in the payloads sent to external
+// systems.
+type scheduleReference struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func referenceTo(schedule scheduleObject) scheduleReference {
+	return scheduleReference{
+		Kind:      scheduleKind(schedule),
+		Name:      schedule.GetName(),
+		Namespace: schedule.GetNamespace(),
+	}
+}
+
+// webhookPayload is the JSON body POSTed to every webhook.
+type webhookPayload struct {
+	Schedule      scheduleReference        `json:"schedule"`
+	Targets       []v1alpha1.TargetRef     `json:"targets"`
+	Phase         v1alpha1.HookPhase       `json:"phase"`
+	ScheduledTime time.Time                `json:"scheduledTime"`
+	Result        v1alpha1.ExecutionResult `json:"result,omitempty"`
+	DryRun        bool                     `json:"dryRun,omitempty"`
+}
+
+// callWebhooks POSTs the execution to the webhooks of the schedule. Before the
+// restart, a gate webhook that cannot be reached or answers with anything but
+// a 2xx status vetoes the restart and a skipError is returned. Failing notify
+// webhooks are only logged.
+func (r *RestartScheduleReconciler) callWebhooks(
+	ctx context.Context,
+	schedule scheduleObject,
+	phase v1alpha1.HookPhase,
+	execution *v1alpha1.RestartExecution,
+) error {
+	if len(schedule.GetScheduleSpec().Webhooks) == 0 {
+		return nil
+	}
+
+	key := client.ObjectKeyFromObject(schedule)
+	logger := r.Log.WithValues("restartschedule", key, "phase", phase)
+
+	ctx, span := r.startSpan(ctx, "Webhooks", key, attribute.String("hook.phase", string(phase)))
+	defer span.End()
+
+	payload := webhookPayload{
+		Schedule:      referenceTo(schedule),
+		Targets:       scheduleTargets(schedule.GetScheduleSpec()),
+		Phase:         phase,
+		ScheduledTime: execution.StartTime.Time,
+		DryRun:        execution.DryRun,
+	}
+	if phase == v1alpha1.PostRestartHook {
+		payload.Result = execution.Result
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range schedule.GetScheduleSpec().Webhooks {
+		gate := webhook.Type == v1alpha1.GateWebhook
+		if gate && phase != v1alpha1.PreRestartHook {
+			continue
+		}
+
+		err := r.postWebhook(ctx, schedule.GetNamespace(), webhook, body)
+		if err == nil {
+			continue
+		}
+		if gate {
+			logger.Info("Restart vetoed by gate webhook", "webhook", webhook.Name, "reason", err.Error())
+			setSpanError(span, err)
+			return &skipError{
+				reason:  "WebhookVetoed",
+				message: fmt.Sprintf("Gate webhook %s vetoed the restart: %v", webhook.Name, err),
+			}
+		}
+		logger.Error(err, "Failed to call webhook", "webhook", webhook.Name)
+	}
+	return nil
+}
+
+func (r *RestartScheduleReconciler) postWebhook(ctx context.Context, namespace string, webhook v1alpha1.RestartWebhook, body []byte) error {
+	timeout := defaultWebhookTimeout
+	if webhook.Timeout != nil {
+		timeout = webhook.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if source := webhook.HeaderFrom; source != nil {
+		var secret corev1.Secret
+		key := types.NamespacedName{Name: source.SecretKeyRef.Name, Namespace: namespace}
+		if err := r.Get(ctx, key, &secret); err != nil {
+			return fmt.Errorf("failed to get header Secret %s: %w", key, err)
+		}
+		if secret.Labels[v1alpha1.WebhookCredentialLabel] != "true" {
+			return fmt.Errorf("header Secret %s is not labeled %s=true", key, v1alpha1.WebhookCredentialLabel)
+		}
+		value, ok := secret.Data[source.SecretKeyRef.Key]
+		if !ok {
+			return fmt.Errorf("key %q not found in Secret %s", source.SecretKeyRef.Key, key)
+		}
+		req.Header.Set(source.Name, string(value))
+	}
+
+	httpClient, err := webhookHTTPClient(webhook.CABundle)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func webhookHTTPClient(caBundle []byte) (*http.Client, error) {
+	if len(caBundle) == 0 {
+		return http.DefaultClient, nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("caBundle does not contain any valid PEM certificate")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport}, nil
+}
+
+// scheduleTargets lists every target of the schedule in the order they are
+// restarted.
+func scheduleTargets(spec *v1alpha1.RestartScheduleSpec) []v1alpha1.TargetRef {
+	var targets []v1alpha1.TargetRef
+	for _, stage := range restartPlan(spec) {
+		targets = append(targets, stage.targets...)
+	}
+	return targets
+}
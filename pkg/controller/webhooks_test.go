@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// webhookRecorder is an HTTP handler that records every payload it receives
+// and answers with a fixed status code.
+type webhookRecorder struct {
+	status   int
+	mu       sync.Mutex
+	payloads []webhookPayload
+	headers  []http.Header
+}
+
+func (w *webhookRecorder) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var payload webhookPayload
+	_ = json.NewDecoder(req.Body).Decode(&payload)
+
+	w.mu.Lock()
+	w.payloads = append(w.payloads, payload)
+	w.headers = append(w.headers, req.Header.Clone())
+	w.mu.Unlock()
+
+	rw.WriteHeader(w.status)
+	_, _ = rw.Write([]byte("change freeze in effect"))
+}
+
+func webhookTestSchedule(webhooks ...v1alpha1.RestartWebhook) *v1alpha1.RestartSchedule {
+	schedule := newTestSchedule()
+	schedule.Spec.Webhooks = webhooks
+	return schedule
+}
+
+func TestGateWebhookVetoesRestart(t *testing.T) {
+	gate := &webhookRecorder{status: http.StatusConflict}
+	gateServer := httptest.NewServer(gate)
+	defer gateServer.Close()
+
+	notify := &webhookRecorder{status: http.StatusOK}
+	notifyServer := httptest.NewServer(notify)
+	defer notifyServer.Close()
+
+	schedule := webhookTestSchedule(
+		v1alpha1.RestartWebhook{Name: "change-management", URL: gateServer.URL, Type: v1alpha1.GateWebhook},
+		v1alpha1.RestartWebhook{Name: "chatops", URL: notifyServer.URL, Type: v1alpha1.NotifyWebhook},
+	)
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, deployment)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+
+	execution := updated.Status.LastExecution
+	require.NotNil(t, execution)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, execution.Result)
+	assert.Contains(t, execution.Message, "change-management")
+	assert.Contains(t, execution.Message, "change freeze in effect")
+	assert.Empty(t, updated.Status.Conditions)
+
+	var untouched appsv1.Deployment
+	require.NoError(t, reconciler.Get(context.Background(),
+		types.NamespacedName{Name: "test-deployment", Namespace: "default"}, &untouched))
+	assert.NotContains(t, untouched.Spec.Template.Annotations, restartedAtAnnotation)
+
+	require.Len(t, gate.payloads, 1)
+	assert.Equal(t, v1alpha1.PreRestartHook, gate.payloads[0].Phase)
+	assert.Equal(t, "test-schedule", gate.payloads[0].Schedule.Name)
+	require.Len(t, gate.payloads[0].Targets, 1)
+	assert.Equal(t, "test-deployment", gate.payloads[0].Targets[0].Name)
+
+	// The gate is listed first, so the notify webhook only hears about the
+	// outcome.
+	require.Len(t, notify.payloads, 1)
+	assert.Equal(t, v1alpha1.PostRestartHook, notify.payloads[0].Phase)
+	assert.Equal(t, v1alpha1.ExecutionSkipped, notify.payloads[0].Result)
+}
+
+func TestGateWebhookWithCABundleAndHeader(t *testing.T) {
+	gate := &webhookRecorder{status: http.StatusOK}
+	gateServer := httptest.NewTLSServer(gate)
+	defer gateServer.Close()
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: gateServer.Certificate().Raw})
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhook-token",
+			Namespace: "default",
+			Labels:    map[string]string{v1alpha1.WebhookCredentialLabel: "true"},
+		},
+		Data: map[string][]byte{"token": []byte("Bearer s3cr3t")},
+	}
+	schedule := webhookTestSchedule(v1alpha1.RestartWebhook{
+		Name:     "change-management",
+		URL:      gateServer.URL,
+		Type:     v1alpha1.GateWebhook,
+		CABundle: caBundle,
+		HeaderFrom: &v1alpha1.WebhookHeaderSource{
+			Name: "Authorization",
+			SecretKeyRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "webhook-token"},
+				Key:                  "token",
+			},
+		},
+	})
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: "default"}}
+	reconciler := newTestReconciler(schedule, deployment, secret)
+
+	cronSchedule, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+
+	key := types.NamespacedName{Name: "test-schedule", Namespace: "default"}
+	reconciler.runScheduledRestart(context.Background(), key, cronSchedule)
+
+	var updated v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), key, &updated))
+	require.NotNil(t, updated.Status.LastExecution)
+	assert.Equal(t, v1alpha1.ExecutionSucceeded, updated.Status.LastExecution.Result)
+
+	require.Len(t, gate.headers, 1)
+	assert.Equal(t, "Bearer s3cr3t", gate.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", gate.headers[0].Get("Content-Type"))
+}
+
+func TestWebhookHeaderFromUnlabeledSecretIsRefused(t *testing.T) {
+	notify := &webhookRecorder{status: http.StatusOK}
+	server := httptest.NewServer(notify)
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "database-password", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+	}
+	webhook := v1alpha1.RestartWebhook{
+		Name: "exfiltrate",
+		URL:  server.URL,
+		HeaderFrom: &v1alpha1.WebhookHeaderSource{
+			Name: "X-Password",
+			SecretKeyRef: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "database-password"},
+				Key:                  "password",
+			},
+		},
+	}
+	reconciler := newTestReconciler(secret)
+
+	err := reconciler.postWebhook(context.Background(), "default", webhook, []byte("{}"))
+	assert.ErrorContains(t, err, "is not labeled "+v1alpha1.WebhookCredentialLabel+"=true")
+	assert.Empty(t, notify.headers)
+}
+
+func TestGateWebhookWithoutTrustedCAVetoes(t *testing.T) {
+	gate := &webhookRecorder{status: http.StatusOK}
+	gateServer := httptest.NewTLSServer(gate)
+	defer gateServer.Close()
+
+	schedule := webhookTestSchedule(v1alpha1.RestartWebhook{
+		Name: "change-management",
+		URL:  gateServer.URL,
+		Type: v1alpha1.GateWebhook,
+	})
+	reconciler := newTestReconciler(schedule)
+
+	execution := &v1alpha1.RestartExecution{StartTime: metav1.Now()}
+	err := reconciler.callWebhooks(context.Background(), schedule, v1alpha1.PreRestartHook, execution)
+
+	var skipped *skipError
+	require.ErrorAs(t, err, &skipped)
+	assert.Equal(t, "WebhookVetoed", skipped.reason)
+	assert.Empty(t, gate.payloads)
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Annotations opting a workload into scheduled restarts without a
+// RestartSchedule of its own.
+const (
+	scheduleAnnotation = "restart-operator.k8s/schedule"
+	timeZoneAnnotation = "restart-operator.k8s/timezone"
+	jitterAnnotation   = "restart-operator.k8s/jitter"
+
+	// generatedLabel marks the RestartSchedules generated from them.
+	generatedLabel = "restart-operator.k8s/generated"
+)
+
+// schedulePattern is the Pattern the CRDs validate the schedule field with.
+// Expressions ParseSchedule accepts beyond it, such as @daily or ranges, would
+// be rejected on every create of the generated schedule.
+var schedulePattern = regexp.MustCompile(`^(\d+|\*)(/\d+)?(\s+(\d+|\*)(/\d+)?){4}$`)
+
+// WorkloadScheduleReconciler generates a RestartSchedule for every
+// Deployment, StatefulSet and DaemonSet annotated with a schedule. The
+// generated schedule is controlled by the workload, so it is garbage-collected
+// together with it, and is deleted again when the annotation is removed.
+type WorkloadScheduleReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments/finalizers;statefulsets/finalizers;daemonsets/finalizers,verbs=update
+func (r *WorkloadScheduleReconciler) reconcileWorkload(ctx context.Context, kind string, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues(strings.ToLower(kind), req.NamespacedName)
+
+	workload := &metav1.PartialObjectMetadata{}
+	workload.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(kind))
+	if err := r.Get(ctx, req.NamespacedName, workload); err != nil {
+		// The generated schedule is garbage-collected with the workload.
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	workload.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(kind))
+
+	_, annotated := workload.GetAnnotations()[scheduleAnnotation]
+
+	key := types.NamespacedName{Name: generatedScheduleName(kind, req.Name), Namespace: req.Namespace}
+	existing := &v1alpha1.RestartSchedule{}
+	if err := r.Get(ctx, key, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		existing = nil
+	} else if !metav1.IsControlledBy(existing, workload) {
+		if annotated {
+			r.Recorder.Event(workload, "Warning", "ScheduleConflict",
+				fmt.Sprintf("RestartSchedule %s already exists and is not generated from this %s", key.Name, kind))
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !annotated || !workload.GetDeletionTimestamp().IsZero() {
+		if existing == nil {
+			return ctrl.Result{}, nil
+		}
+		if err := r.Delete(ctx, existing); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		logger.Info("Deleted generated RestartSchedule", "restartschedule", key)
+		return ctrl.Result{}, nil
+	}
+
+	spec, err := scheduleFromAnnotations(kind, workload)
+	if err != nil {
+		logger.Info("Ignoring invalid schedule annotations", "reason", err.Error())
+		r.Recorder.Event(workload, "Warning", "InvalidAnnotation", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	if existing == nil {
+		schedule := &v1alpha1.RestartSchedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            key.Name,
+				Namespace:       key.Namespace,
+				Labels:          map[string]string{generatedLabel: "true"},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(workload, workload.GroupVersionKind())},
+			},
+			Spec: spec,
+		}
+		if err := r.Create(ctx, schedule, client.FieldOwner(fieldManager)); err != nil {
+			return r.rejected(workload, err)
+		}
+		logger.Info("Created generated RestartSchedule", "restartschedule", key)
+		return ctrl.Result{}, nil
+	}
+
+	// A generated schedule may be suspended like any other.
+	spec.Suspend = existing.Spec.Suspend
+	base := existing.DeepCopy()
+	existing.Spec = spec
+	metav1.SetMetaDataLabel(&existing.ObjectMeta, generatedLabel, "true")
+	if equality.Semantic.DeepEqual(base.Spec, existing.Spec) && equality.Semantic.DeepEqual(base.Labels, existing.Labels) {
+		return ctrl.Result{}, nil
+	}
+	if err := r.Patch(ctx, existing, client.MergeFrom(base), client.FieldOwner(fieldManager)); err != nil {
+		return r.rejected(workload, err)
+	}
+	logger.Info("Updated generated RestartSchedule", "restartschedule", key)
+	return ctrl.Result{}, nil
+}
+
+// rejected reports a generated schedule the API server refused on the
+// workload instead of retrying it, since the same annotations would be
+// refused again. Other errors are retried.
+func (r *WorkloadScheduleReconciler) rejected(workload client.Object, err error) (ctrl.Result, error) {
+	if !apierrors.IsInvalid(err) {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(workload, "Warning", "InvalidAnnotation", fmt.Sprintf("generated RestartSchedule was rejected: %v", err))
+	return ctrl.Result{}, nil
+}
+
+// generatedScheduleName names the RestartSchedule generated for a workload.
+// The kind is part of the name, so a Deployment and a StatefulSet of the same
+// name get a schedule each. Names that would be too long are truncated and
+// made unique again with a hash of the full name.
+func generatedScheduleName(kind, name string) string {
+	generated := strings.ToLower(kind) + "-" + name
+	if len(generated) <= validation.DNS1123SubdomainMaxLength {
+		return generated
+	}
+	sum := sha256.Sum256([]byte(generated))
+	suffix := "-" + hex.EncodeToString(sum[:])[:10]
+	prefix := strings.TrimRight(generated[:validation.DNS1123SubdomainMaxLength-len(suffix)], ".-")
+	return prefix + suffix
+}
+
+// scheduleFromAnnotations builds the spec of the generated schedule from the
+// annotations of the workload.
+func scheduleFromAnnotations(kind string, workload client.Object) (v1alpha1.RestartScheduleSpec, error) {
+	annotations := workload.GetAnnotations()
+	spec := v1alpha1.RestartScheduleSpec{
+		Schedule:  strings.TrimSpace(annotations[scheduleAnnotation]),
+		TimeZone:  strings.TrimSpace(annotations[timeZoneAnnotation]),
+		TargetRef: &v1alpha1.TargetRef{Kind: kind, Name: workload.GetName()},
+	}
+	if !schedulePattern.MatchString(spec.Schedule) {
+		return spec, fmt.Errorf("invalid %s annotation: %q is not five fields of numbers, * and steps", scheduleAnnotation, spec.Schedule)
+	}
+	if _, err := ParseSchedule(&spec); err != nil {
+		return spec, fmt.Errorf("invalid %s annotation: %w", scheduleAnnotation, err)
+	}
+	if value, ok := annotations[jitterAnnotation]; ok {
+		jitter, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return spec, fmt.Errorf("invalid %s annotation: %w", jitterAnnotation, err)
+		}
+		spec.Jitter = &metav1.Duration{Duration: jitter}
+	}
+	return spec, nil
+}
+
+// SetupWithManager registers a controller per workload kind. Workloads are
+// watched as metadata only, and only annotation changes trigger a reconcile.
+func (r *WorkloadScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	for _, kind := range watchedTargetKinds {
+		workload := &metav1.PartialObjectMetadata{}
+		workload.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind(kind))
+
+		err := ctrl.NewControllerManagedBy(mgr).
+			Named(strings.ToLower(kind)+"-schedule").
+			For(workload, builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+			Owns(&v1alpha1.RestartSchedule{}).
+			Complete(reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+				return r.reconcileWorkload(ctx, kind, req)
+			}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archsyscall/restart-operator/pkg/apis/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newWorkloadTestReconciler(objs ...client.Object) (*WorkloadScheduleReconciler, *record.FakeRecorder) {
+	s := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(s)
+	_ = appsv1.AddToScheme(s)
+
+	recorder := record.NewFakeRecorder(10)
+	return &WorkloadScheduleReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build(),
+		Scheme:   s,
+		Recorder: recorder,
+	}, recorder
+}
+
+func TestAnnotatedWorkloadGetsSchedule(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "default",
+			UID:       "api-uid",
+			Annotations: map[string]string{
+				scheduleAnnotation: "0 3 * * *",
+				timeZoneAnnotation: "Europe/Berlin",
+				jitterAnnotation:   "10m",
+			},
+		},
+	}
+	reconciler, _ := newWorkloadTestReconciler(deployment)
+
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "api", Namespace: "default"}}
+	key := types.NamespacedName{Name: "deployment-api", Namespace: "default"}
+
+	_, err := reconciler.reconcileWorkload(ctx, "Deployment", req)
+	require.NoError(t, err)
+
+	var schedule v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(ctx, key, &schedule))
+	assert.Equal(t, v1alpha1.RestartScheduleSpec{
+		Schedule:  "0 3 * * *",
+		TimeZone:  "Europe/Berlin",
+		Jitter:    &metav1.Duration{Duration: 10 * time.Minute},
+		TargetRef: &v1alpha1.TargetRef{Kind: "Deployment", Name: "api"},
+	}, schedule.Spec)
+	assert.Equal(t, "true", schedule.Labels[generatedLabel])
+	owner := metav1.GetControllerOf(&schedule)
+	require.NotNil(t, owner)
+	assert.Equal(t, "Deployment", owner.Kind)
+	assert.Equal(t, types.UID("api-uid"), owner.UID)
+
+	schedule.Spec.Suspend = true
+	require.NoError(t, reconciler.Update(ctx, &schedule))
+
+	deployment.Annotations = map[string]string{scheduleAnnotation: "30 4 * * 1"}
+	require.NoError(t, reconciler.Update(ctx, deployment))
+	_, err = reconciler.reconcileWorkload(ctx, "Deployment", req)
+	require.NoError(t, err)
+
+	require.NoError(t, reconciler.Get(ctx, key, &schedule))
+	assert.Equal(t, "30 4 * * 1", schedule.Spec.Schedule)
+	assert.True(t, schedule.Spec.Suspend, "suspending a generated schedule must not be reverted")
+	assert.Empty(t, schedule.Spec.TimeZone)
+	assert.Nil(t, schedule.Spec.Jitter)
+
+	deployment.Annotations = nil
+	require.NoError(t, reconciler.Update(ctx, deployment))
+	_, err = reconciler.reconcileWorkload(ctx, "Deployment", req)
+	require.NoError(t, err)
+
+	err = reconciler.Get(ctx, key, &schedule)
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestInvalidWorkloadAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		message     string
+	}{
+		{
+			name:        "schedule",
+			annotations: map[string]string{scheduleAnnotation: "every night"},
+			message:     scheduleAnnotation,
+		},
+		{
+			name:        "schedule the CRD rejects",
+			annotations: map[string]string{scheduleAnnotation: "0 3 * * 1-5"},
+			message:     "five fields",
+		},
+		{
+			name:        "time zone",
+			annotations: map[string]string{scheduleAnnotation: "0 3 * * *", timeZoneAnnotation: "Nowhere/Special"},
+			message:     "invalid time zone",
+		},
+		{
+			name:        "jitter",
+			annotations: map[string]string{scheduleAnnotation: "0 3 * * *", jitterAnnotation: "a while"},
+			message:     jitterAnnotation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statefulSet := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Annotations: tt.annotations},
+			}
+			reconciler, recorder := newWorkloadTestReconciler(statefulSet)
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "db", Namespace: "default"}}
+			_, err := reconciler.reconcileWorkload(context.Background(), "StatefulSet", req)
+			require.NoError(t, err)
+
+			var schedules v1alpha1.RestartScheduleList
+			require.NoError(t, reconciler.List(context.Background(), &schedules))
+			assert.Empty(t, schedules.Items)
+
+			require.Len(t, recorder.Events, 1)
+			event := <-recorder.Events
+			assert.Contains(t, event, "InvalidAnnotation")
+			assert.Contains(t, event, tt.message)
+		})
+	}
+}
+
+func TestWorkloadLeavesHandWrittenScheduleAlone(t *testing.T) {
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "agent",
+			Namespace:   "default",
+			Annotations: map[string]string{scheduleAnnotation: "0 3 * * *"},
+		},
+	}
+	existing := &v1alpha1.RestartSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "daemonset-agent", Namespace: "default"},
+		Spec: v1alpha1.RestartScheduleSpec{
+			Schedule:  "0 5 * * *",
+			TargetRef: &v1alpha1.TargetRef{Kind: "DaemonSet", Name: "agent"},
+		},
+	}
+	reconciler, recorder := newWorkloadTestReconciler(daemonSet, existing)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "agent", Namespace: "default"}}
+	_, err := reconciler.reconcileWorkload(context.Background(), "DaemonSet", req)
+	require.NoError(t, err)
+
+	var schedule v1alpha1.RestartSchedule
+	require.NoError(t, reconciler.Get(context.Background(), client.ObjectKeyFromObject(existing), &schedule))
+	assert.Equal(t, "0 5 * * *", schedule.Spec.Schedule)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "ScheduleConflict")
+}
+
+func TestGeneratedScheduleNameIsTruncated(t *testing.T) {
+	assert.Equal(t, "deployment-api", generatedScheduleName("Deployment", "api"))
+
+	// The name is cut right after the dot, which may not end it.
+	long := strings.Repeat("a", 229) + "." + strings.Repeat("b", 30)
+	name := generatedScheduleName("StatefulSet", long)
+	assert.LessOrEqual(t, len(name), 253)
+	assert.Empty(t, validation.IsDNS1123Subdomain(name))
+	assert.NotEqual(t, name, generatedScheduleName("StatefulSet", long+"c"))
+}